@@ -3,9 +3,12 @@ package cuei
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/big"
+	"strings"
 )
 
 // chk generic catchall error checking
@@ -17,10 +20,47 @@ func chk(e error) {
 }
 
 // decB64 decodes base64 strings.
-func decB64(b64 string) []byte {
-	deb64, err := base64.StdEncoding.DecodeString(b64)
-	chk(err)
-	return deb64
+func decB64(b64 string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(b64)
+}
+
+// decHex decodes a hex string with an optional 0x prefix, any other character is an error.
+func decHex(str string) ([]byte, error) {
+	if strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X") {
+		str = str[2:]
+	}
+	return hex.DecodeString(str)
+}
+
+/*
+tolerantHex decodes hex dumps like "FC 30 16" or "fc:30:16".
+
+	Spaces, colons and line breaks between bytes are dropped
+	and case is ignored. ok is false unless the result is hex
+	with a 0x prefix or starting with the 0xfc table id,
+	so base64 and decimal strings are left alone.
+*/
+func tolerantHex(str string) ([]byte, bool) {
+	str = strings.TrimSpace(str)
+	prefixed := strings.HasPrefix(str, "0x") || strings.HasPrefix(str, "0X")
+	if prefixed {
+		str = str[2:]
+	}
+	str = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', ':', '\t', '\r', '\n':
+			return -1
+		}
+		return r
+	}, str)
+	if !prefixed && !strings.HasPrefix(strings.ToLower(str), "fc") {
+		return nil, false
+	}
+	bites, err := hex.DecodeString(str)
+	if err != nil {
+		return nil, false
+	}
+	return bites, true
 }
 
 // encB64 encodes  bytes to a Base64 string
@@ -47,11 +87,62 @@ func isIn[T comparable](slice []T, val T) bool {
 	return false
 }
 
+// rollOver is where 33 bit 90k PTS values wrap.
+const rollOver = 1 << 33
+
+// wrapPts rounds secs to 90k ticks and wraps it into the 33 bit PTS range.
+func wrapPts(secs float64) float64 {
+	ticks := int64(math.Round(secs*90000.0)) % rollOver
+	if ticks < 0 {
+		ticks += rollOver
+	}
+	return mk90k(uint64(ticks))
+}
+
 func mk90k(raw uint64) float64 {
 	nk := float64(raw) / 90000.0
 	return float64(uint64(nk*1000000)) / 1000000
 }
 
+/*
+ScalePTS converts pts90k, a PTS in seconds on the 90k clock like Command.PTS,
+to ticks of toTimescale, like 10000000 or 48000 for DASH and CMAF timing.
+
+	pts90k is rounded to 90k ticks and scaled as the ratio
+	toTimescale / 90000, rounded to the nearest tick,
+	so there is no float drift on long durations.
+	ScalePTS returns 0 when toTimescale is not positive.
+*/
+func ScalePTS(pts90k float64, toTimescale int) uint64 {
+	if toTimescale <= 0 || pts90k <= 0 {
+		return 0
+	}
+	return scaleTicks(u64(pts90k), 90000, uint64(toTimescale))
+}
+
+/*
+UnscalePTS is the inverse of ScalePTS, it converts ticks of fromTimescale
+to seconds on the 90k clock, rounded to 90k ticks.
+
+	The seconds are not truncated to microseconds,
+	so ScalePTS of the result returns ticks again.
+	UnscalePTS returns 0 when fromTimescale is not positive.
+*/
+func UnscalePTS(ticks uint64, fromTimescale int) float64 {
+	if fromTimescale <= 0 {
+		return 0
+	}
+	return float64(scaleTicks(ticks, uint64(fromTimescale), 90000)) / 90000.0
+}
+
+// scaleTicks returns ticks * to / from, rounded to the nearest tick.
+func scaleTicks(ticks, from, to uint64) uint64 {
+	n := new(big.Int).SetUint64(ticks)
+	n.Mul(n, new(big.Int).SetUint64(to))
+	n.Add(n, new(big.Int).SetUint64(from/2))
+	return n.Quo(n, new(big.Int).SetUint64(from)).Uint64()
+}
+
 // mkJson structs to JSON
 func mkJson(i interface{}) string {
 	jason, err := json.MarshalIndent(&i, "", "    ")
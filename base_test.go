@@ -0,0 +1,41 @@
+package cuei_test
+
+import (
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestScalePTS(t *testing.T) {
+	// 2^33 - 1 ticks is 95443.717677 seconds
+	long := 95443.717677
+	tests := []struct {
+		pts       float64
+		timescale int
+		want      uint64
+	}{
+		{10.0, 10000000, 100000000},
+		{1.001, 48000, 48048},
+		{1.001, 30000, 30030},
+		{long, 90000, 1<<33 - 1},
+		{long, 10000000, 954437176778},
+		{long, 48000, 4581298449},
+		{10.0, 0, 0},
+	}
+	for _, test := range tests {
+		got := cuei.ScalePTS(test.pts, test.timescale)
+		if got != test.want {
+			t.Errorf("ScalePTS(%v, %v) is %v, want %v", test.pts, test.timescale, got, test.want)
+		}
+		if test.timescale == 0 {
+			continue
+		}
+		back := cuei.UnscalePTS(got, test.timescale)
+		if again := cuei.ScalePTS(back, test.timescale); again != got {
+			t.Errorf("ScalePTS(UnscalePTS(%v, %v)) is %v", got, test.timescale, again)
+		}
+	}
+	if secs := cuei.UnscalePTS(48048, 48000); secs != 1.001 {
+		t.Errorf("UnscalePTS(48048, 48000) is %v, want 1.001", secs)
+	}
+}
@@ -6,27 +6,33 @@ import (
 	"math/big"
 )
 
-// Decoder converts bytes to a list of bits.
+// Decoder reads bits from bytes.
 type bitDecoder struct {
-	idx  uint
-	bits string
+	idx     uint
+	bites   []byte
+	overrun bool // set when a read goes past the end of bites
 }
 
-// Load raw bytes and convert to bits
+// Load raw bytes, they are read in place and not copied.
 func (bd *bitDecoder) load(bites []byte) {
-	i := new(big.Int)
-	i.SetBytes(bites)
-	bd.bits = fmt.Sprintf("%b", i)
+	bd.bites = bites
 	bd.idx = 0
+	bd.overrun = false
 }
 
-// chunk slices bitcount of bits and returns it as a uint64
-func (bd *bitDecoder) chunk(bitcount uint) *big.Int {
-	j := new(big.Int)
-	d := bd.idx + bitcount
-	j.SetString(bd.bits[bd.idx:d], 2)
-	bd.idx = d
-	return j
+// bit returns the bit at idx.
+func (bd *bitDecoder) bit(idx uint) uint64 {
+	return uint64(bd.bites[idx>>3]>>(7-idx&7)) & 1
+}
+
+// fits is true when bitcount bits are left, otherwise it sets overrun and skips them.
+func (bd *bitDecoder) fits(bitcount uint) bool {
+	if bd.idx+bitcount > uint(len(bd.bites))<<3 {
+		bd.overrun = true
+		bd.idx += bitcount
+		return false
+	}
+	return true
 }
 
 // uInt8 trims uint64 to 8 bits
@@ -50,11 +56,22 @@ func (bd *bitDecoder) uInt32(bitcount uint) uint32 {
 
 }
 
-// uInt64 is a wrapper for chunk
+// uInt64 slices bitcount of bits and returns them as a uint64, past 64 bits the low 64 are kept.
 func (bd *bitDecoder) uInt64(bitcount uint) uint64 {
-	j := bd.chunk(bitcount)
-	return j.Uint64()
-
+	if !bd.fits(bitcount) {
+		return 0
+	}
+	var j uint64
+	for end := bd.idx + bitcount; bd.idx < end; {
+		if bd.idx&7 == 0 && end-bd.idx >= 8 {
+			j = j<<8 | uint64(bd.bites[bd.idx>>3])
+			bd.idx += 8
+			continue
+		}
+		j = j<<1 | bd.bit(bd.idx)
+		bd.idx++
+	}
+	return j
 }
 
 // asFlag slices 1 bit and returns true for 1 , false for 0
@@ -84,10 +101,24 @@ func (bd *bitDecoder) asHex(bitcount uint) string {
 	return ashex
 }
 
-// asBytes slices bitcount of bits and returns as []bytes
+// asBytes slices bitcount of bits and returns as []bytes, leading zero bytes are kept.
 func (bd *bitDecoder) asBytes(bitcount uint) []byte {
-	j := bd.chunk(bitcount)
-	return j.Bytes()
+	n := (bitcount + 7) >> 3
+	out := make([]byte, n)
+	if !bd.fits(bitcount) {
+		return out
+	}
+	if bd.idx&7 == 0 && bitcount&7 == 0 {
+		copy(out, bd.bites[bd.idx>>3:])
+		bd.idx += bitcount
+		return out
+	}
+	// right aligned, the first n*8-bitcount bits are 0
+	for at := n<<3 - bitcount; at < n<<3; at++ {
+		out[at>>3] |= byte(bd.bit(bd.idx) << (7 - at&7))
+		bd.idx++
+	}
+	return out
 }
 
 // asAscii returns the ascii chars of Bytes
@@ -2,7 +2,7 @@ package cuei
 
 import (
 	"fmt"
-	)
+)
 
 /*
 Command
@@ -10,38 +10,96 @@ Command
 	These Splice Command types are consolidated into Command.
 
 	     0x0: Splice Null,
+	     0x4: Splice Schedule,
 	     0x5: Splice Insert,
 	     0x6: Time Signal,
 	     0x7: Bandwidth Reservation,
 	     0xff: Private,
+
+	Fields not used by the CommandType are left at zero,
+	the As methods return typed views holding only the used fields.
+
+	     Splice Insert: SpliceEventID through PTS, and Components.
+	     Time Signal: TimeSpecifiedFlag and PTS.
+	     Splice Schedule: SpliceEvents.
+	     Private: Identifier and PrivateBytes.
 */
 type Command struct {
 	Name                       string
 	CommandType                uint8
-	PrivateBytes               []byte  `json:",omitempty"`
-	Identifier                 uint32  `json:",omitempty"`
-	SpliceEventID              uint32  `json:",omitempty"`
-	SpliceEventCancelIndicator bool    `json:",omitempty"`
-	OutOfNetworkIndicator      bool    `json:",omitempty"`
-	ProgramSpliceFlag          bool    `json:",omitempty"`
-	DurationFlag               bool    `json:",omitempty"`
-	BreakAutoReturn            bool    `json:",omitempty"`
-	BreakDuration              float64 `json:",omitempty"`
-	SpliceImmediateFlag        bool    `json:",omitempty"`
-	UniqueProgramID            uint16  `json:",omitempty"`
-	AvailNum                   uint8   `json:",omitempty"`
-	AvailExpected              uint8   `json:",omitempty"`
-	TimeSpecifiedFlag          bool    `json:",omitempty"`
-	PTS                        float64 `json:",omitempty"`
+	PrivateBytes               []byte            `json:",omitempty"`
+	Identifier                 uint32            `json:",omitempty"`
+	SpliceEventID              uint32            `json:",omitempty"`
+	SpliceEventCancelIndicator bool              `json:",omitempty"`
+	OutOfNetworkIndicator      bool              `json:",omitempty"`
+	ProgramSpliceFlag          bool              `json:",omitempty"`
+	DurationFlag               bool              `json:",omitempty"`
+	BreakAutoReturn            bool              `json:",omitempty"`
+	BreakDuration              float64           `json:",omitempty"`
+	SpliceImmediateFlag        bool              `json:",omitempty"`
+	UniqueProgramID            uint16            `json:",omitempty"`
+	AvailNum                   uint8             `json:",omitempty"`
+	AvailExpected              uint8             `json:",omitempty"`
+	TimeSpecifiedFlag          bool              `json:",omitempty"`
+	PTS                        float64           `json:",omitempty"`
+	PTSRaw                     uint64            `json:",omitempty"` // PTS in 90k ticks as on the wire, set by Decode and Encode
+	Components                 []SpliceComponent `json:",omitempty"`
+	SpliceEvents               []SpliceEvent     `json:",omitempty"`
+	RawBytes                   []byte            `json:"-"` // the command bytes, set by Decode, all the encrypted bytes of a Ciphertext Cue
+}
+
+// utcUnspecified is the utc_splice_time sentinel for an unspecified time.
+const utcUnspecified = 0xffffffff
+
+/*
+SpliceEvent is a splice event in a Splice Schedule.
+
+	UTCSpliceTime is seconds since 00:00 UTC January 6th, 1980.
+	TimeUnspecified is set for the all ones utc_splice_time sentinel.
+*/
+type SpliceEvent struct {
+	SpliceEventID              uint32
+	SpliceEventCancelIndicator bool                `json:",omitempty"`
+	OutOfNetworkIndicator      bool                `json:",omitempty"`
+	ProgramSpliceFlag          bool                `json:",omitempty"`
+	DurationFlag               bool                `json:",omitempty"`
+	UTCSpliceTime              uint32              `json:",omitempty"`
+	TimeUnspecified            bool                `json:",omitempty"`
+	Components                 []ScheduleComponent `json:",omitempty"`
+	BreakAutoReturn            bool                `json:",omitempty"`
+	BreakDuration              float64             `json:",omitempty"`
+	UniqueProgramID            uint16              `json:",omitempty"`
+	AvailNum                   uint8               `json:",omitempty"`
+	AvailExpected              uint8               `json:",omitempty"`
+}
+
+// ScheduleComponent is a component splice of a SpliceEvent.
+type ScheduleComponent struct {
+	ComponentTag    uint8
+	UTCSpliceTime   uint32 `json:",omitempty"`
+	TimeUnspecified bool   `json:",omitempty"`
+}
+
+/*
+SpliceComponent is a component splice of a Splice Insert
+with ProgramSpliceFlag not set.
+
+	TimeSpecifiedFlag and PTS are only used
+	when SpliceImmediateFlag is not set.
+*/
+type SpliceComponent struct {
+	ComponentTag      uint8
+	TimeSpecifiedFlag bool    `json:",omitempty"`
+	PTS               float64 `json:",omitempty"`
 }
 
 // Return Command as JSON
-func (cmd *Command)Json() string{
-	return mkJson(cmd)	
+func (cmd *Command) Json() string {
+	return mkJson(cmd)
 }
 
 // Print Command as JSON
-func (cmd *Command)Show(){
+func (cmd *Command) Show() {
 	fmt.Printf(cmd.Json())
 }
 
@@ -51,6 +109,8 @@ func (cmd *Command) Decode(cmdtype uint8, bd *bitDecoder) {
 	switch cmdtype {
 	case 0x0:
 		cmd.decodeSpliceNull(bd)
+	case 0x4:
+		cmd.decodeSpliceSchedule(bd)
 	case 0x5:
 		cmd.decodeSpliceInsert(bd)
 	case 0x6:
@@ -68,6 +128,9 @@ func (cmd *Command) Decode(cmdtype uint8, bd *bitDecoder) {
 func (cmd *Command) Encode() []byte {
 	blank := []byte{}
 	switch cmd.CommandType {
+	case 0x4:
+		return cmd.encodeSpliceSchedule()
+
 	case 0x5:
 		return cmd.encodeSpliceInsert()
 
@@ -78,6 +141,96 @@ func (cmd *Command) Encode() []byte {
 
 }
 
+/*
+EncodeBytes returns the splice command bytes of cmd alone,
+without the splice info section around them,
+for carrying the command in another protocol.
+
+	The layout by CommandType is:
+	     0x0 Splice Null and 0x7 Bandwidth Reservation: no bytes.
+	     0x4 Splice Schedule: splice_count, then each event,
+	         splice_event_id, flags, a utc_splice_time or the components,
+	         break_duration if DurationFlag, unique_program_id, avail_num and avails_expected.
+	     0x5 Splice Insert: splice_event_id, flags, splice_time() or the components,
+	         break_duration if DurationFlag, unique_program_id, avail_num and avails_expected.
+	     0x6 Time Signal: splice_time(), 1 byte or 5 with a PTS.
+
+	Other command types return an error.
+*/
+func (cmd *Command) EncodeBytes() ([]byte, error) {
+	switch cmd.CommandType {
+	case 0x0, 0x4, 0x5, 0x6, 0x7:
+		return cmd.Encode(), nil
+	}
+	return nil, fmt.Errorf("command type %#x can not be encoded", cmd.CommandType)
+}
+
+// encodedLen is len(cmd.Encode()), counted from the fields.
+func (cmd *Command) encodedLen() int {
+	switch cmd.CommandType {
+	case 0x4:
+		n := 1 // splice_count
+		for _, evt := range cmd.SpliceEvents {
+			n += evt.encodedLen()
+		}
+		return n
+
+	case 0x5:
+		// splice_event_id and the cancel indicator
+		if cmd.SpliceEventCancelIndicator {
+			return 5
+		}
+		// + flags, unique_program_id, avail_num and avails_expected
+		n := 6 + 4
+		if cmd.ProgramSpliceFlag {
+			if !cmd.SpliceImmediateFlag {
+				n += spliceTimeLen(cmd.TimeSpecifiedFlag)
+			}
+		} else {
+			n++ // component_count
+			for _, comp := range cmd.Components {
+				n++
+				if !cmd.SpliceImmediateFlag {
+					n += spliceTimeLen(comp.TimeSpecifiedFlag)
+				}
+			}
+		}
+		if cmd.DurationFlag {
+			n += 5
+		}
+		return n
+
+	case 0x6:
+		return spliceTimeLen(cmd.TimeSpecifiedFlag)
+	}
+	return 0
+}
+
+// encodedLen is the bytes encode writes for evt.
+func (evt *SpliceEvent) encodedLen() int {
+	if evt.SpliceEventCancelIndicator {
+		return 5
+	}
+	n := 6 + 4
+	if evt.ProgramSpliceFlag {
+		n += 4
+	} else {
+		n += 1 + 5*len(evt.Components)
+	}
+	if evt.DurationFlag {
+		n += 5
+	}
+	return n
+}
+
+// spliceTimeLen is the bytes of a splice_time(), 5 with a PTS or 1 without.
+func spliceTimeLen(specified bool) int {
+	if specified {
+		return 5
+	}
+	return 1
+}
+
 // bandwidth Reservation
 func (cmd *Command) decodeBandwidthReservation(bd *bitDecoder) {
 	cmd.Name = "Bandwidth Reservation"
@@ -97,19 +250,128 @@ func (cmd *Command) decodeSpliceNull(bd *bitDecoder) {
 	bd.goForward(0)
 }
 
+// splice Schedule
+func (cmd *Command) decodeSpliceSchedule(bd *bitDecoder) {
+	cmd.Name = "Splice Schedule"
+	count := bd.uInt8(8)
+	for count > 0 {
+		count--
+		var evt SpliceEvent
+		evt.decode(bd)
+		cmd.SpliceEvents = append(cmd.SpliceEvents, evt)
+	}
+}
+
+// encode Splice Schedule Splice Command
+func (cmd *Command) encodeSpliceSchedule() []byte {
+	be := &bitEncoder{}
+	be.Add(1, 8) //bumper
+	be.Add(uint8(len(cmd.SpliceEvents)), 8)
+	for _, evt := range cmd.SpliceEvents {
+		evt.encode(be)
+	}
+	return be.Bites.Bytes()[1:]
+}
+
+// decode a Splice Schedule splice event
+func (evt *SpliceEvent) decode(bd *bitDecoder) {
+	evt.SpliceEventID = bd.uInt32(32)
+	evt.SpliceEventCancelIndicator = bd.asFlag()
+	bd.goForward(7)
+	if evt.SpliceEventCancelIndicator {
+		return
+	}
+	evt.OutOfNetworkIndicator = bd.asFlag()
+	evt.ProgramSpliceFlag = bd.asFlag()
+	evt.DurationFlag = bd.asFlag()
+	bd.goForward(5)
+	if evt.ProgramSpliceFlag {
+		evt.UTCSpliceTime, evt.TimeUnspecified = decodeUTC(bd)
+	} else {
+		count := bd.uInt8(8)
+		for count > 0 {
+			count--
+			var comp ScheduleComponent
+			comp.ComponentTag = bd.uInt8(8)
+			comp.UTCSpliceTime, comp.TimeUnspecified = decodeUTC(bd)
+			evt.Components = append(evt.Components, comp)
+		}
+	}
+	if evt.DurationFlag {
+		evt.BreakAutoReturn, evt.BreakDuration = DecodeBreakDuration(bd.uInt64(40))
+	}
+	evt.UniqueProgramID = bd.uInt16(16)
+	evt.AvailNum = bd.uInt8(8)
+	evt.AvailExpected = bd.uInt8(8)
+}
+
+// encode a Splice Schedule splice event
+func (evt *SpliceEvent) encode(be *bitEncoder) {
+	be.Add(evt.SpliceEventID, 32)
+	be.Add(evt.SpliceEventCancelIndicator, 1)
+	be.Reserve(7)
+	if evt.SpliceEventCancelIndicator {
+		return
+	}
+	be.Add(evt.OutOfNetworkIndicator, 1)
+	be.Add(evt.ProgramSpliceFlag, 1)
+	be.Add(evt.DurationFlag, 1)
+	be.Reserve(5)
+	if evt.ProgramSpliceFlag {
+		encodeUTC(be, evt.UTCSpliceTime, evt.TimeUnspecified)
+	} else {
+		be.Add(uint8(len(evt.Components)), 8)
+		for _, comp := range evt.Components {
+			be.Add(comp.ComponentTag, 8)
+			encodeUTC(be, comp.UTCSpliceTime, comp.TimeUnspecified)
+		}
+	}
+	if evt.DurationFlag {
+		be.Add(EncodeBreakDuration(evt.BreakAutoReturn, evt.BreakDuration), 40)
+	}
+	be.Add(evt.UniqueProgramID, 16)
+	be.Add(evt.AvailNum, 8)
+	be.Add(evt.AvailExpected, 8)
+}
+
+// decodeUTC reads a utc_splice_time, unspecified is true for the sentinel.
+func decodeUTC(bd *bitDecoder) (utc uint32, unspecified bool) {
+	utc = bd.uInt32(32)
+	if utc == utcUnspecified {
+		return 0, true
+	}
+	return utc, false
+}
+
+// encodeUTC writes a utc_splice_time, or the sentinel when unspecified.
+func encodeUTC(be *bitEncoder, utc uint32, unspecified bool) {
+	if unspecified {
+		utc = utcUnspecified
+	}
+	be.Add(utc, 32)
+}
+
 // splice Insert
 func (cmd *Command) decodeSpliceInsert(bd *bitDecoder) {
 	cmd.Name = "Splice Insert"
 	cmd.SpliceEventID = bd.uInt32(32)
 	cmd.SpliceEventCancelIndicator = bd.asFlag()
 	bd.goForward(7)
+	// a cancel has only the event id
+	if cmd.SpliceEventCancelIndicator {
+		return
+	}
 	cmd.OutOfNetworkIndicator = bd.asFlag()
 	cmd.ProgramSpliceFlag = bd.asFlag()
 	cmd.DurationFlag = bd.asFlag()
 	cmd.SpliceImmediateFlag = bd.asFlag()
 	bd.goForward(4)
-	if !cmd.SpliceImmediateFlag {
-		cmd.spliceTime(bd)
+	if cmd.ProgramSpliceFlag {
+		if !cmd.SpliceImmediateFlag {
+			cmd.spliceTime(bd)
+		}
+	} else {
+		cmd.decodeComponents(bd)
 	}
 	if cmd.DurationFlag == true {
 		cmd.parseBreak(bd)
@@ -126,13 +388,20 @@ func (cmd *Command) encodeSpliceInsert() []byte {
 	be.Add(cmd.SpliceEventID, 32)
 	be.Add(cmd.SpliceEventCancelIndicator, 1)
 	be.Reserve(7)
+	if cmd.SpliceEventCancelIndicator {
+		return be.Bites.Bytes()[1:]
+	}
 	be.Add(cmd.OutOfNetworkIndicator, 1)
 	be.Add(cmd.ProgramSpliceFlag, 1)
 	be.Add(cmd.DurationFlag, 1)
 	be.Add(cmd.SpliceImmediateFlag, 1)
 	be.Reserve(4)
-	if !cmd.SpliceImmediateFlag {
-		cmd.encodeSpliceTime(be)
+	if cmd.ProgramSpliceFlag {
+		if !cmd.SpliceImmediateFlag {
+			cmd.encodeSpliceTime(be)
+		}
+	} else {
+		cmd.encodeComponents(be)
 	}
 	if cmd.DurationFlag {
 		cmd.encodeBreak(be)
@@ -144,37 +413,94 @@ func (cmd *Command) encodeSpliceInsert() []byte {
 
 }
 
+// component mode Splice Insert, each component has its own splice time
+func (cmd *Command) decodeComponents(bd *bitDecoder) {
+	count := bd.uInt8(8)
+	cmd.Components = nil
+	for i := uint8(0); i < count; i++ {
+		var comp SpliceComponent
+		comp.ComponentTag = bd.uInt8(8)
+		if !cmd.SpliceImmediateFlag {
+			specified, ticks := decodeSpliceTime(bd)
+			comp.TimeSpecifiedFlag, comp.PTS = specified, mk90k(ticks)
+		}
+		cmd.Components = append(cmd.Components, comp)
+	}
+}
+
+func (cmd *Command) encodeComponents(be *bitEncoder) {
+	be.Add(len(cmd.Components), 8)
+	for _, comp := range cmd.Components {
+		be.Add(comp.ComponentTag, 8)
+		if !cmd.SpliceImmediateFlag {
+			encodeSpliceTime(be, comp.TimeSpecifiedFlag, comp.PTS)
+		}
+	}
+}
+
 func (cmd *Command) encodeBreak(be *bitEncoder) {
-	be.Add(cmd.BreakAutoReturn, 1)
-	be.Reserve(6)
-	be.Add(cmd.BreakDuration, 33)
+	be.Add(EncodeBreakDuration(cmd.BreakAutoReturn, cmd.BreakDuration), 40)
+}
+
+/*
+EncodeBreakDuration packs a 40 bit break_duration.
+
+	bit  39     auto_return
+	bits 38-33  reserved, set to 1
+	bits 32-0   duration in 90k ticks
+*/
+func EncodeBreakDuration(autoReturn bool, secs float64) uint64 {
+	v := uint64(0x3f) << 33
+	if autoReturn {
+		v |= 1 << 39
+	}
+	return v | u64(secs)&(rollOver-1)
+}
+
+// DecodeBreakDuration unpacks a 40 bit break_duration packed by EncodeBreakDuration.
+func DecodeBreakDuration(v uint64) (autoReturn bool, secs float64) {
+	return v&(1<<39) != 0, mk90k(v & (rollOver - 1))
 }
 
 // encode PTS splice times
 func (cmd *Command) encodeSpliceTime(be *bitEncoder) {
-	be.Add(cmd.TimeSpecifiedFlag, 1)
-	if cmd.TimeSpecifiedFlag == true {
+	cmd.PTSRaw = 0
+	if cmd.TimeSpecifiedFlag {
+		cmd.PTSRaw = u64(cmd.PTS) & (rollOver - 1)
+	}
+	encodeSpliceTime(be, cmd.TimeSpecifiedFlag, cmd.PTS)
+}
+
+// encodeSpliceTime writes a splice_time()
+func encodeSpliceTime(be *bitEncoder, specified bool, pts float64) {
+	be.Add(specified, 1)
+	if specified == true {
 		be.Reserve(6)
-		be.Add(cmd.PTS, 33)
+		be.Add(pts, 33)
 		return
 	}
 	be.Reserve(7)
 }
 
 func (cmd *Command) parseBreak(bd *bitDecoder) {
-	cmd.BreakAutoReturn = bd.asFlag()
-	bd.goForward(6)
-	cmd.BreakDuration = bd.as90k(33)
+	cmd.BreakAutoReturn, cmd.BreakDuration = DecodeBreakDuration(bd.uInt64(40))
 }
 
 func (cmd *Command) spliceTime(bd *bitDecoder) {
-	cmd.TimeSpecifiedFlag = bd.asFlag()
-	if cmd.TimeSpecifiedFlag {
+	cmd.TimeSpecifiedFlag, cmd.PTSRaw = decodeSpliceTime(bd)
+	cmd.PTS = mk90k(cmd.PTSRaw)
+}
+
+// decodeSpliceTime reads a splice_time(), ticks is the 33 bit pts_time.
+func decodeSpliceTime(bd *bitDecoder) (specified bool, ticks uint64) {
+	specified = bd.asFlag()
+	if specified {
 		bd.goForward(6)
-		cmd.PTS = bd.as90k(33)
+		ticks = bd.uInt64(33)
 	} else {
 		bd.goForward(7)
 	}
+	return specified, ticks
 }
 
 // decode Time Signal Splice Commands
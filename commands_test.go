@@ -0,0 +1,239 @@
+package cuei_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestSpliceScheduleUnspecifiedTime(t *testing.T) {
+	events := []cuei.SpliceEvent{
+		{SpliceEventID: 1, OutOfNetworkIndicator: true, ProgramSpliceFlag: true, TimeUnspecified: true},
+		{SpliceEventID: 2, ProgramSpliceFlag: true, UTCSpliceTime: 1300000000, UniqueProgramID: 7},
+	}
+	cue := withCommand(&cuei.Command{CommandType: 0x4, SpliceEvents: events})
+	// the sentinel is all ones on the wire
+	if !bytes.Contains(cue.Encode(), []byte{0xdf, 0xff, 0xff, 0xff, 0xff}) {
+		t.Errorf("utc_splice_time sentinel not encoded in %x", cue.Encode())
+	}
+	got := roundTrip(t, cue).Command
+	if got.Name != "Splice Schedule" || len(got.SpliceEvents) != 2 {
+		t.Fatalf("decoded %v with %v events", got.Name, len(got.SpliceEvents))
+	}
+	for i, evt := range got.SpliceEvents {
+		if fmt.Sprint(evt) != fmt.Sprint(events[i]) {
+			t.Errorf("event %v is %+v, want %+v", i, evt, events[i])
+		}
+	}
+}
+
+func TestSpliceInsertCancel(t *testing.T) {
+	cmd := &cuei.Command{
+		CommandType:                0x5,
+		SpliceEventID:              0x4800008f,
+		SpliceEventCancelIndicator: true,
+		OutOfNetworkIndicator:      true, // not encoded for a cancel
+		ProgramSpliceFlag:          true,
+	}
+	cue := withCommand(cmd)
+	if cue.InfoSection.CommandLength != 5 {
+		t.Errorf("CommandLength is %v, want 5", cue.InfoSection.CommandLength)
+	}
+	got := roundTrip(t, cue).Command
+	if got.SpliceEventID != 0x4800008f || !got.SpliceEventCancelIndicator || got.OutOfNetworkIndicator {
+		t.Errorf("decoded cancel %+v", got)
+	}
+	if len(got.RawBytes) != 5 {
+		t.Errorf("decoded %v command bytes, want 5", len(got.RawBytes))
+	}
+}
+
+func TestSpliceInsertModes(t *testing.T) {
+	program := &cuei.Command{
+		CommandType:       0x5,
+		SpliceEventID:     1,
+		ProgramSpliceFlag: true,
+		TimeSpecifiedFlag: true,
+		PTS:               100.0,
+		// ignored in program mode
+		Components: []cuei.SpliceComponent{{ComponentTag: 1}},
+	}
+	component := &cuei.Command{
+		CommandType:       0x5,
+		SpliceEventID:     2,
+		TimeSpecifiedFlag: true, // ignored in component mode
+		PTS:               100.0,
+		Components: []cuei.SpliceComponent{
+			{ComponentTag: 1, TimeSpecifiedFlag: true, PTS: 200.0},
+			{ComponentTag: 2},
+		},
+	}
+	immediate := &cuei.Command{
+		CommandType:         0x5,
+		SpliceEventID:       3,
+		SpliceImmediateFlag: true,
+		Components:          []cuei.SpliceComponent{{ComponentTag: 1}, {ComponentTag: 2}},
+	}
+	// 4 event id, 1 cancel, 1 flags, 4 unique program id and avails
+	// program: 5 splice time, component: 1 count, 8 components, immediate: 1 count, 2 tags
+	lengths := []int{15, 19, 13}
+	for i, cmd := range []*cuei.Command{program, component, immediate} {
+		cue := roundTrip(t, withCommand(cmd))
+		got := cue.Command
+		if len(got.RawBytes) != lengths[i] {
+			t.Errorf("event %v command is %v bytes, want %v", cmd.SpliceEventID, len(got.RawBytes), lengths[i])
+		}
+		if cmd.ProgramSpliceFlag {
+			if len(got.Components) != 0 || got.PTS != 100.0 {
+				t.Errorf("program mode decoded %v components, pts %v", len(got.Components), got.PTS)
+			}
+			continue
+		}
+		if got.TimeSpecifiedFlag || got.PTS != 0 || len(got.Components) != 2 {
+			t.Errorf("component mode decoded pts %v and %v components", got.PTS, len(got.Components))
+			continue
+		}
+		if got.Components[0].PTS != cmd.Components[0].PTS || got.Components[1].TimeSpecifiedFlag {
+			t.Errorf("component mode decoded %+v", got.Components)
+		}
+	}
+}
+
+func TestDurationWithoutAutoReturn(t *testing.T) {
+	cue := roundTrip(t, withCommand(&cuei.Command{
+		CommandType:           0x5,
+		SpliceEventID:         1,
+		OutOfNetworkIndicator: true,
+		ProgramSpliceFlag:     true,
+		DurationFlag:          true,
+		BreakDuration:         30.0,
+		TimeSpecifiedFlag:     true,
+		PTS:                   10.0,
+	}))
+	cmd := cue.Command
+	if !cmd.DurationFlag || cmd.BreakAutoReturn || cmd.BreakDuration != 30.0 {
+		t.Errorf("break decoded as flag %v auto return %v duration %v", cmd.DurationFlag, cmd.BreakAutoReturn, cmd.BreakDuration)
+	}
+	// break_duration follows the 5 byte splice time, auto_return is its top bit.
+	if b := cmd.RawBytes[11]; b != 0x7e {
+		t.Errorf("break_duration starts %#x, want 0x7e", b)
+	}
+	start, end, ok := cue.AvailWindow()
+	if !ok || start != 10.0 || end != 40.0 {
+		t.Errorf("window is %v to %v (%v)", start, end, ok)
+	}
+}
+
+func TestSpliceScheduleMixedEvents(t *testing.T) {
+	events := []cuei.SpliceEvent{
+		{SpliceEventID: 1, OutOfNetworkIndicator: true, ProgramSpliceFlag: true, DurationFlag: true,
+			UTCSpliceTime: 1300000000, BreakAutoReturn: true, BreakDuration: 60.0, AvailNum: 1, AvailExpected: 2},
+		{SpliceEventID: 2, OutOfNetworkIndicator: true, DurationFlag: true, BreakDuration: 30.5,
+			Components: []cuei.ScheduleComponent{
+				{ComponentTag: 0x1, UTCSpliceTime: 1300000060},
+				{ComponentTag: 0x2, TimeUnspecified: true},
+			}, UniqueProgramID: 7},
+		{SpliceEventID: 3, SpliceEventCancelIndicator: true},
+		{SpliceEventID: 4, Components: []cuei.ScheduleComponent{{ComponentTag: 0x3, UTCSpliceTime: 1300000120}}},
+	}
+	cue := withCommand(&cuei.Command{CommandType: 0x4, SpliceEvents: events})
+	got := roundTrip(t, cue).Command
+	if len(got.SpliceEvents) != len(events) {
+		t.Fatalf("decoded %v events, want %v", len(got.SpliceEvents), len(events))
+	}
+	for i, evt := range got.SpliceEvents {
+		if fmt.Sprint(evt) != fmt.Sprint(events[i]) {
+			t.Errorf("event %v is %+v, want %+v", i, evt, events[i])
+		}
+	}
+}
+
+func TestTimeSignalLength(t *testing.T) {
+	cases := []struct {
+		specified bool
+		length    uint16
+	}{
+		{false, 1},
+		{true, 5},
+	}
+	for _, c := range cases {
+		cue := withDescriptors(segmentation(0x34))
+		cue.Command.TimeSpecifiedFlag = c.specified
+		cue = roundTrip(t, cue)
+		cmd := cue.Command
+		if cue.InfoSection.CommandLength != c.length || len(cmd.RawBytes) != int(c.length) {
+			t.Errorf("time specified %v command length is %v with %v raw bytes, want %v",
+				c.specified, cue.InfoSection.CommandLength, len(cmd.RawBytes), c.length)
+		}
+		if cmd.TimeSpecifiedFlag != c.specified || len(cue.Warnings) != 0 {
+			t.Errorf("time specified %v decoded as %v with warnings %v", c.specified, cmd.TimeSpecifiedFlag, cue.Warnings)
+		}
+		if len(cue.Descriptors) != 1 || cue.Descriptors[0].SegmentationTypeID != 0x34 {
+			t.Errorf("time specified %v descriptors misread after the command: %+v", c.specified, cue.Descriptors)
+		}
+	}
+}
+
+func TestPTSRaw(t *testing.T) {
+	// pts_time 0x07369c02e and pts_adjustment 0
+	cue := cuei.NewCue()
+	cue.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	if cue.Command.PTSRaw != 0x07369c02e || cue.InfoSection.PtsAdjustmentRaw != 0 {
+		t.Errorf("pts raw %#x pts adjustment raw %#x", cue.Command.PTSRaw, cue.InfoSection.PtsAdjustmentRaw)
+	}
+	cue.Command.PTS = 10.0
+	cue.InfoSection.PtsAdjustment = 95443.717677 // 2^33 - 1 ticks
+	cue.Encode()
+	if cue.Command.PTSRaw != 900000 || cue.InfoSection.PtsAdjustmentRaw != 1<<33-1 {
+		t.Errorf("encoded pts raw %v pts adjustment raw %#x", cue.Command.PTSRaw, cue.InfoSection.PtsAdjustmentRaw)
+	}
+	cue2 := roundTrip(t, cue)
+	if cue2.Command.PTSRaw != cue.Command.PTSRaw || cue2.InfoSection.PtsAdjustmentRaw != cue.InfoSection.PtsAdjustmentRaw {
+		t.Errorf("decoded pts raw %v pts adjustment raw %#x", cue2.Command.PTSRaw, cue2.InfoSection.PtsAdjustmentRaw)
+	}
+}
+
+func TestSpliceScheduleDurationFlag(t *testing.T) {
+	with := cuei.SpliceEvent{SpliceEventID: 1, OutOfNetworkIndicator: true, ProgramSpliceFlag: true, DurationFlag: true,
+		UTCSpliceTime: 1300000000, BreakAutoReturn: true, BreakDuration: 30.0, UniqueProgramID: 0x1234, AvailNum: 1, AvailExpected: 2}
+	without := with
+	without.DurationFlag, without.BreakAutoReturn, without.BreakDuration = false, false, 0
+	long := withCommand(&cuei.Command{CommandType: 0x4, SpliceEvents: []cuei.SpliceEvent{with}})
+	short := withCommand(&cuei.Command{CommandType: 0x4, SpliceEvents: []cuei.SpliceEvent{without}})
+	// break_duration is 5 bytes
+	if d := long.InfoSection.CommandLength - short.InfoSection.CommandLength; d != 5 {
+		t.Errorf("break duration adds %v bytes, want 5", d)
+	}
+	events := []cuei.SpliceEvent{without, with, without}
+	got := roundTrip(t, withCommand(&cuei.Command{CommandType: 0x4, SpliceEvents: events})).Command.SpliceEvents
+	if len(got) != len(events) {
+		t.Fatalf("decoded %v events, want %v", len(got), len(events))
+	}
+	for i, evt := range got {
+		if fmt.Sprint(evt) != fmt.Sprint(events[i]) {
+			t.Errorf("event %v is %+v, want %+v", i, evt, events[i])
+		}
+	}
+}
+
+func TestCommandEncodeBytes(t *testing.T) {
+	cue := cuei.NewCue()
+	cue.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	b, err := cue.Command.EncodeBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, cue.Command.RawBytes) {
+		t.Errorf("splice insert bytes are %x, want %x", b, cue.Command.RawBytes)
+	}
+	for _, typ := range []uint8{0x0, 0x7} {
+		if b, err := (&cuei.Command{CommandType: typ}).EncodeBytes(); err != nil || len(b) != 0 {
+			t.Errorf("command type %#x is %x, err %v", typ, b, err)
+		}
+	}
+	if _, err := (&cuei.Command{CommandType: 0xff}).EncodeBytes(); err == nil {
+		t.Error("private command encoded")
+	}
+}
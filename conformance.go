@@ -0,0 +1,210 @@
+package cuei
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Level is the outcome of a conformance Rule.
+type Level string
+
+// Conformance Levels
+const (
+	Pass Level = "pass"
+	Warn Level = "warn"
+	Fail Level = "fail"
+)
+
+// Rule is a named conformance check run against a Cue.
+type Rule struct {
+	Name  string
+	Check func(cue *Cue) (Level, string)
+}
+
+/*
+Rules are run in order by Cue.ConformanceReport.
+
+Append a Rule to add a custom check.
+*/
+var Rules = []Rule{
+	{"table_id", chkTableID},
+	{"reserved_bits", chkReserved},
+	{"section_length", chkSectionLength},
+	{"crc32", chkCrc32},
+	{"command_type", chkCommandType},
+	{"command_length", chkCommandLength},
+	{"descriptor_tags", chkDescriptorTags},
+	{"segmentation_type_id", chkSegmentationTypes},
+	{"decode_warnings", chkWarnings},
+}
+
+// Result is the Level and message from one Rule.
+type Result struct {
+	Rule    string
+	Level   Level
+	Message string `json:",omitempty"`
+}
+
+// Report holds a Result for each Rule.
+type Report struct {
+	Level   Level // the worst Level of the Results
+	Results []Result
+}
+
+// Return Report as JSON
+func (report *Report) Json() string {
+	return mkJson(report)
+}
+
+// Print Report as JSON
+func (report *Report) Show() {
+	fmt.Println(report.Json())
+}
+
+// Validate returns an error listing the failed Rules of cue.ConformanceReport, or nil.
+func (cue *Cue) Validate() error {
+	var fails []string
+	for _, result := range cue.ConformanceReport().Results {
+		if result.Level == Fail {
+			fails = append(fails, result.Rule+": "+result.Message)
+		}
+	}
+	if len(fails) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(fails, "; "))
+}
+
+// ConformanceReport runs each of Rules against cue and returns a Report.
+func (cue *Cue) ConformanceReport() Report {
+	report := Report{Level: Pass}
+	for _, rule := range Rules {
+		level, msg := rule.Check(cue)
+		report.Results = append(report.Results, Result{rule.Name, level, msg})
+		if level == Fail || (level == Warn && report.Level == Pass) {
+			report.Level = level
+		}
+	}
+	return report
+}
+
+func chkTableID(cue *Cue) (Level, string) {
+	if cue.InfoSection == nil {
+		return Fail, "no splice info section"
+	}
+	if cue.InfoSection.TableID != "0xfc" {
+		return Fail, fmt.Sprintf("table id is %v, not 0xfc", cue.InfoSection.TableID)
+	}
+	return Pass, ""
+}
+
+func chkReserved(cue *Cue) (Level, string) {
+	if cue.InfoSection == nil {
+		return Fail, "no splice info section"
+	}
+	infosec := cue.InfoSection
+	if infosec.SectionSyntaxIndicator || infosec.Private {
+		return Warn, "section syntax indicator and private indicator should be 0"
+	}
+	if infosec.Reserved != "0x3" {
+		return Warn, fmt.Sprintf("reserved bits are %v, not 0x3", infosec.Reserved)
+	}
+	return Pass, ""
+}
+
+func chkSectionLength(cue *Cue) (Level, string) {
+	if cue.InfoSection == nil {
+		return Fail, "no splice info section"
+	}
+	infosec := cue.InfoSection
+	// 11 bytes for info section + command + 2 descriptor loop length
+	// + descriptor loop + alignment stuffing + 4 for crc
+	need := 11 + int(infosec.CommandLength) + 2 + int(cue.Dll) + int(infosec.Stuffing) + 4
+	if cue.Ciphertext && cue.Command != nil {
+		// the encrypted bytes are the command, descriptor loop and stuffing
+		need = 11 + len(cue.Command.RawBytes) + 4
+	}
+	have := int(infosec.SectionLength)
+	switch {
+	case cue.bites != nil && have+3 > len(cue.bites):
+		return Fail, fmt.Sprintf("section length %v is more than the %v bytes decoded", have, len(cue.bites)-3)
+	case have < need:
+		return Fail, fmt.Sprintf("section length %v is less than the %v bytes needed", have, need)
+	case have > need:
+		return Warn, fmt.Sprintf("section length %v has %v bytes of stuffing", have, have-need)
+	}
+	return Pass, ""
+}
+
+func chkCrc32(cue *Cue) (Level, string) {
+	if cue.bites == nil {
+		return Warn, "cue was not decoded from bytes"
+	}
+	end := int(cue.InfoSection.SectionLength) + 3
+	if end > len(cue.bites) || end < 4 {
+		return Fail, "section is too short for a crc"
+	}
+	crc := cRC32(cue.bites[:end-4])
+	if crc != cue.Crc32 {
+		return Fail, fmt.Sprintf("crc32 is %#x, want %#x", cue.Crc32, crc)
+	}
+	return Pass, ""
+}
+
+func chkCommandType(cue *Cue) (Level, string) {
+	if cue.Command == nil {
+		return Fail, "no splice command"
+	}
+	if cue.Command.Name == "" && !cue.Ciphertext {
+		return Fail, fmt.Sprintf("unknown splice command type %#x", cue.Command.CommandType)
+	}
+	return Pass, ""
+}
+
+// chkCommandLength cross checks splice_command_length and the command type, 0xfff is the legacy unknown length.
+func chkCommandLength(cue *Cue) (Level, string) {
+	if cue.InfoSection == nil || cue.Command == nil {
+		return Fail, "no splice command"
+	}
+	length := cue.InfoSection.CommandLength
+	switch {
+	case length == 0xfff:
+		return Pass, ""
+	case cue.Command.CommandType == 0x0 && length != 0:
+		return Fail, fmt.Sprintf("splice null has a command length of %v bytes", length)
+	case cue.Command.CommandType != 0x0 && length == 0:
+		return Warn, fmt.Sprintf("command type %#x has a command length of 0", cue.Command.CommandType)
+	}
+	return Pass, ""
+}
+
+func chkDescriptorTags(cue *Cue) (Level, string) {
+	for _, dscptr := range cue.Descriptors {
+		_, registered := codecFor(dscptr.Tag, dscptr.identifier())
+		if dscptr.Tag > 0x4 && !registered {
+			return Warn, fmt.Sprintf("unknown splice descriptor tag %#x", dscptr.Tag)
+		}
+	}
+	return Pass, ""
+}
+
+func chkSegmentationTypes(cue *Cue) (Level, string) {
+	for _, dscptr := range cue.Descriptors {
+		if dscptr.Tag != 0x2 || dscptr.SegmentationEventCancelIndicator {
+			continue
+		}
+		_, ok := table22[dscptr.SegmentationTypeID]
+		if !ok {
+			return Warn, fmt.Sprintf("unknown segmentation type id %#x", dscptr.SegmentationTypeID)
+		}
+	}
+	return Pass, ""
+}
+
+func chkWarnings(cue *Cue) (Level, string) {
+	if len(cue.Warnings) > 0 {
+		return Warn, fmt.Sprintf("%v decode warnings: %v", len(cue.Warnings), cue.Warnings[0])
+	}
+	return Pass, ""
+}
@@ -0,0 +1,54 @@
+package cuei_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestConformanceReport(t *testing.T) {
+	cue := withDescriptors(segmentation(0x34))
+	if report := cue.ConformanceReport(); report.Level != cuei.Pass || len(report.Results) != len(cuei.Rules) {
+		t.Errorf("report is %v", report.Json())
+	}
+	bad := withDescriptors(segmentation(0x34))
+	bad.InfoSection.TableID = "0xfd"
+	bad.Encode()
+	if err := bad.Validate(); err == nil || !strings.Contains(err.Error(), "table_id") {
+		t.Errorf("bad table id validates as %v", err)
+	}
+	if report := (&cuei.Cue{}).ConformanceReport(); report.Level != cuei.Fail {
+		t.Errorf("empty cue is %v", report.Level)
+	}
+}
+
+func TestConformanceCiphertext(t *testing.T) {
+	cue := withDescriptors(segmentation(0x34))
+	cue.InfoSection.EncryptedPacket = true
+	encrypted := cuei.NewCue()
+	encrypted.Decode(cue.Encode())
+	for _, result := range encrypted.ConformanceReport().Results {
+		if result.Level != cuei.Pass && result.Rule != "decode_warnings" {
+			t.Errorf("%v is %v, %v", result.Rule, result.Level, result.Message)
+		}
+	}
+}
+
+func TestConformanceCustomRule(t *testing.T) {
+	rules := cuei.Rules
+	t.Cleanup(func() { cuei.Rules = rules })
+	cuei.Rules = append(cuei.Rules[:len(cuei.Rules):len(cuei.Rules)], cuei.Rule{Name: "no_time_signals", Check: func(cue *cuei.Cue) (cuei.Level, string) {
+		if cue.Command != nil && cue.Command.CommandType == 0x6 {
+			return cuei.Warn, "time signal"
+		}
+		return cuei.Pass, ""
+	}})
+	report := withDescriptors().ConformanceReport()
+	if last := report.Results[len(report.Results)-1]; report.Level != cuei.Warn || last.Rule != "no_time_signals" || last.Message != "time signal" {
+		t.Errorf("report is %v", report.Json())
+	}
+	if err := withDescriptors().Validate(); err != nil {
+		t.Errorf("a warning fails Validate, %v", err)
+	}
+}
@@ -1,7 +1,15 @@
 package cuei
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"math"
 	"math/big"
 )
 
@@ -17,6 +25,17 @@ A Cue contains:
 	   	0 or more Splice Descriptors
 	   	1 Crc32
 	   	0 or 1 packetData (if parsed from MPEGTS)
+	   	0 or more Warnings (problems found during decoding)
+
+	A Partial Cue has no Descriptors, encoding it drops them,
+	MarshalBinary returns an error instead.
+	A Ciphertext Cue was decoded from an encrypted packet,
+	only its InfoSection is decoded, the encrypted bytes are in Command.RawBytes
+	and Encode writes them back as they are.
+
+	Command.PTS and InfoSection.PtsAdjustment are decoded and encoded
+	as they are on the wire, pts_adjustment is never folded into the PTS,
+	EffectivePTS returns their sum.
 */
 type Cue struct {
 	InfoSection *InfoSection
@@ -25,69 +44,318 @@ type Cue struct {
 	Descriptors []Descriptor `json:",omitempty"`
 	PacketData  *packetData  `json:",omitempty"`
 	Crc32       uint32
+	Warnings    []string `json:",omitempty"`
+	Partial     bool     `json:",omitempty"` // decoded with Decoder.HeaderOnly, Descriptors were skipped
+	Ciphertext  bool     `json:",omitempty"` // decoded from an encrypted packet, Command and Descriptors are not decoded
+	bites       []byte   // the bytes the Cue was decoded from
+	str         string   // the string the Cue was decoded from
 }
 
-// Decode takes Cue data as  []byte, base64 or hex string.
+/*
+Decode takes Cue data as  []byte, base64 or hex string.
+
+	Hex may be a pasted dump with spaces or colons between bytes,
+	use DecodeHex to reject anything but hex digits.
+*/
 func (cue *Cue) Decode(i interface{}) bool {
+	return cue.decode(&Decoder{}, i) == nil
+}
+
+// decode converts i to bytes and decodes them with dec.
+func (cue *Cue) decode(dec *Decoder, i interface{}) error {
 	switch i.(type) {
 	case string:
 		str := i.(string)
+		cue.str = str
+		if bites, ok := tolerantHex(str); ok {
+			return cue.decodeBytes(dec, bites)
+		}
 		j := new(big.Int)
 		_, err := fmt.Sscan(str, j)
 		if err != nil {
-			return cue.decodeBytes(decB64(str))
+			bites, err := decB64(str)
+			if err != nil {
+				return err
+			}
+			return cue.decodeBytes(dec, bites)
 		}
-		return cue.decodeBytes(j.Bytes())
+		return cue.decodeBytes(dec, j.Bytes())
 
 	default:
-		return cue.decodeBytes(i.([]byte))
+		cue.str = ""
+		return cue.decodeBytes(dec, i.([]byte))
 	}
 }
 
 // decodeBytes extracts bits for the Cue values.
-func (cue *Cue) decodeBytes(bites []byte) bool {
+func (cue *Cue) decodeBytes(dec *Decoder, bites []byte) error {
 	var bd bitDecoder
 	bd.load(bites)
+	cue.bites = append([]byte(nil), bites...)
 	cue.InfoSection = &InfoSection{}
-	if cue.InfoSection.Decode(&bd) {
-		cue.Command = &Command{}
-		cue.Command.Decode(cue.InfoSection.CommandType, &bd)
-		cue.Dll = bd.uInt16(16)
-		cue.dscptrLoop(cue.Dll, &bd)
-		cue.Crc32 = bd.uInt32(32)
-		return true
+	if !cue.InfoSection.Decode(&bd) {
+		return errors.New("not a splice info section")
 	}
-	return false
+	if cue.InfoSection.TableID != "0xfc" {
+		err := dec.warn(cue, "table id is %v, not 0xfc", cue.InfoSection.TableID)
+		if err != nil {
+			return fmt.Errorf("%w, it is %v", ErrBadTableID, cue.InfoSection.TableID)
+		}
+	}
+	cue.InfoSection.RawBytes = rawBytes(bites, 0, bd.idx)
+	if cue.InfoSection.EncryptedPacket {
+		return cue.decodeCiphertext(dec, &bd, bites)
+	}
+	cue.Command = &Command{}
+	start := bd.idx
+	cue.Command.Decode(cue.InfoSection.CommandType, &bd)
+	cue.Command.RawBytes = rawBytes(bites, start, bd.idx)
+	if dec.OnCommand != nil {
+		dec.OnCommand(cue.Command)
+	}
+	cue.Dll = bd.uInt16(16)
+	if dec.Recover && cue.Dll == 0 {
+		err := cue.recoverDll(dec, &bd, len(bites))
+		if err != nil {
+			return err
+		}
+	}
+	err := cue.boundDll(dec, &bd, len(bites))
+	if err != nil {
+		return err
+	}
+	if dec.HeaderOnly {
+		cue.Partial = true
+		bd.goForward(uint(cue.Dll) << 3)
+	} else {
+		err = cue.dscptrLoop(dec, cue.Dll, &bd)
+		if err != nil {
+			return err
+		}
+	}
+	if dec.Strict {
+		err = cue.sectionFill(bites, bd.idx>>3)
+		if err != nil {
+			return err
+		}
+	}
+	cue.alignmentStuffing(&bd, len(bites))
+	cue.Crc32 = bd.uInt32(32)
+	if bd.overrun {
+		return errors.New("cue is truncated")
+	}
+	if dec.Strict {
+		return trailing(bites, bd.idx>>3)
+	}
+	return nil
+}
+
+/*
+decodeCiphertext keeps the encrypted bytes of an encrypted packet
+as cue.Command.RawBytes and reads the crc32, the command and descriptors
+are not decoded.
+
+	The packet is not an error, even for a Strict Decoder,
+	a warning is recorded and cue.Ciphertext is set.
+*/
+func (cue *Cue) decodeCiphertext(dec *Decoder, bd *bitDecoder, bites []byte) error {
+	cue.Ciphertext = true
+	cue.Warnings = append(cue.Warnings, "encrypted packet, the splice command and descriptors are not decrypted")
+	start := int(bd.idx >> 3)
+	end := int(cue.InfoSection.SectionLength) + 3 - 4
+	if end < start || end+4 > len(bites) {
+		return errors.New("cue is truncated")
+	}
+	cue.Command = &Command{
+		CommandType: cue.InfoSection.CommandType,
+		RawBytes:    append([]byte(nil), bites[start:end]...),
+	}
+	bd.goForward(uint(end-start) << 3)
+	cue.Crc32 = bd.uInt32(32)
+	if dec.Strict {
+		return trailing(bites, bd.idx>>3)
+	}
+	return nil
+}
+
+/*
+recoverDll sets cue.Dll to the bytes between the descriptor loop length
+and the crc32 when an encoder wrote a loop length of 0 before descriptors,
+the section length marks where the crc32 starts.
+*/
+func (cue *Cue) recoverDll(dec *Decoder, bd *bitDecoder, size int) error {
+	end := int(cue.InfoSection.SectionLength) + 3 - 4
+	if end > size {
+		return nil
+	}
+	left := end - int(bd.idx>>3)
+	if left <= 0 {
+		return nil
+	}
+	err := dec.warn(cue, "descriptor loop length is 0 but %v bytes are before the crc32, decoding them as descriptors", left)
+	if err != nil {
+		return err
+	}
+	cue.Dll = uint16(left)
+	return nil
+}
+
+/*
+boundDll cuts cue.Dll to the bytes before the crc32 when it is more,
+the crc32 starts 4 bytes before the end of the section,
+or of the bytes when section_length is past them.
+
+	A smaller Dll is not a disagreement, the bytes left are alignment stuffing.
+*/
+func (cue *Cue) boundDll(dec *Decoder, bd *bitDecoder, size int) error {
+	end := int(cue.InfoSection.SectionLength) + 3
+	if end > size {
+		end = size
+	}
+	bound := end - 4 - int(bd.idx>>3)
+	if bound < 0 {
+		bound = 0
+	}
+	if int(cue.Dll) <= bound {
+		return nil
+	}
+	err := dec.warn(cue, "descriptor loop length %v is more than the %v bytes before the crc32, using %v", cue.Dll, bound, bound)
+	if err != nil {
+		return err
+	}
+	cue.Dll = uint16(bound)
+	return nil
+}
+
+// alignmentStuffing skips the bytes section_length puts between the descriptor loop and the crc32.
+func (cue *Cue) alignmentStuffing(bd *bitDecoder, size int) {
+	end := int(cue.InfoSection.SectionLength) + 3 - 4
+	at := int(bd.idx >> 3)
+	if end <= at || end+4 > size {
+		return
+	}
+	cue.InfoSection.Stuffing = uint16(end - at)
+	bd.goForward(uint(end-at) << 3)
+}
+
+/*
+sectionFill returns an ErrSectionLength error when the command,
+descriptor loop and crc32 do not fill section_length, at is the byte
+after the descriptor loop.
+
+	Bytes left before the crc32 are only alignment stuffing when each is 0xff,
+	anything else is extra data, like a second Cue glued inside the section.
+	A section_length past the end of bites is left to boundDll.
+*/
+func (cue *Cue) sectionFill(bites []byte, at uint) error {
+	length := int(cue.InfoSection.SectionLength)
+	end := length + 3
+	if end > len(bites) {
+		return nil
+	}
+	need := int(at) + 4
+	if need > end {
+		return fmt.Errorf("%w, section length %v is %v bytes short of the command, descriptor loop and crc32", ErrSectionLength, length, need-end)
+	}
+	for _, b := range bites[at : end-4] {
+		if b != 0xff {
+			return fmt.Errorf("%w, section length %v has %v bytes more than the command, descriptor loop and crc32", ErrSectionLength, length, end-need)
+		}
+	}
+	return nil
+}
+
+// trailing returns an error when the bytes after the crc32 are not 0xff stuffing.
+func trailing(bites []byte, end uint) error {
+	for i, b := range bites[end:] {
+		if b != 0xff {
+			return fmt.Errorf("%v trailing bytes after the crc32, byte %v is %#x not 0xff stuffing", len(bites)-int(end), i, b)
+		}
+	}
+	return nil
+}
+
+// rawBytes returns a copy of the bytes from bit start to bit end.
+func rawBytes(bites []byte, start uint, end uint) []byte {
+	start, end = start>>3, end>>3
+	if end > uint(len(bites)) || start > end {
+		return nil
+	}
+	return append([]byte(nil), bites[start:end]...)
 }
 
 // DscptrLoop loops over any splice descriptors
-func (cue *Cue) dscptrLoop(dll uint16, bd *bitDecoder) {
+func (cue *Cue) dscptrLoop(dec *Decoder, dll uint16, bd *bitDecoder) error {
 	var i uint16
 	i = 0
 	l := dll
+	loopEnd := bd.idx + uint(dll)<<3
 	for i < l {
 		tag := bd.uInt8(8)
 		i++
 		length := bd.uInt16(8)
 		i++
+		var left uint16
+		if l > i {
+			left = l - i
+		}
+		if length > left {
+			err := dec.warn(cue, "descriptor tag %#x length %v is more than the %v bytes left in the loop", tag, length, left)
+			if err != nil {
+				return err
+			}
+			length = left
+		}
 		i += length
-		var sdr Descriptor
-		sdr.Decode(bd, tag, uint8(length))
+		start := bd.idx
+		sdr := Descriptor{lazyUpid: dec.LazyUPIDs}
+		err := sdr.Decode(bd, tag, uint8(length))
+		if err != nil {
+			err = dec.warn(cue, "descriptor tag %#x %v", tag, err)
+			if err != nil {
+				return err
+			}
+		}
+		if _, private := privateCodecs[sdr.Identifier]; sdr.Identifier != cueIdentifier && !private {
+			err := dec.warn(cue, "descriptor tag %#x identifier is %#x not %#x (CUEI)", tag, sdr.Identifier, cueIdentifier)
+			if err != nil {
+				return err
+			}
+		}
+		used := int(bd.idx-start+7) >> 3
+		if used != int(length) {
+			err := dec.warn(cue, "descriptor tag %#x length is %v bytes, %v were decoded (%+d)", tag, length, used, used-int(length))
+			if err != nil {
+				return err
+			}
+		}
+		if used < int(length) {
+			// keep private bytes after the fields of a known tag
+			bd.idx = start + uint(used)<<3
+			sdr.Trailing = bd.asBytes(uint(int(length)-used) << 3)
+		}
+		bd.idx = start + uint(length)<<3
 		cue.Descriptors = append(cue.Descriptors, sdr)
+		if dec.OnDescriptor != nil {
+			dec.OnDescriptor(&cue.Descriptors[len(cue.Descriptors)-1])
+		}
 	}
+	bd.idx = loopEnd
+	return nil
 }
 
 func (cue *Cue) rollLoop() []byte {
 	be := &bitEncoder{}
 	be.Add(1, 8) //bumper
-	for _, dscptr := range cue.Descriptors {
+	for i := range cue.Descriptors {
+		dscptr := &cue.Descriptors[i]
 		bf := &bitEncoder{}
 		bf.Add(1, 8) //bumper to keep leading zeros
 		dscptr.Encode(bf)
 		be.Add(dscptr.Tag, 8)
 		// +3 is  +4 for identifier and -1 for the bumper.
 		be.Add(len(bf.Bites.Bytes())+3, 8)
-		be.AddBytes([]byte("CUEI"), 32)
+		be.Add(dscptr.identifier(), 32)
 		dscptr.Encode(be)
 	}
 	cue.Dll = uint16(len(be.Bites.Bytes()) - 1)
@@ -105,27 +373,292 @@ func (cue *Cue) AdjustPts(seconds float64) {
 	cue.Encode()
 }
 
+/*
+ShiftTime adds delta seconds to the splice times of cue and re-encodes it.
+
+	These fields are shifted:
+		Command.PTS and SpliceComponent PTS, wrapped at 33 bits,
+		when TimeSpecifiedFlag is set.
+		SpliceEvent UTCSpliceTime and ScheduleComponent UTCSpliceTime,
+		rounded to whole seconds, when TimeUnspecified is not set.
+
+	InfoSection.PtsAdjustment, durations and pts offsets are not changed.
+	A Cue with no InfoSection or Command is not changed.
+*/
+func (cue *Cue) ShiftTime(delta float64) {
+	if cue.InfoSection == nil || cue.Command == nil {
+		return
+	}
+	cmd := cue.Command
+	if cmd.TimeSpecifiedFlag && !cmd.SpliceImmediateFlag {
+		cmd.PTS = wrapPts(cmd.PTS + delta)
+	}
+	for i := range cmd.Components {
+		comp := &cmd.Components[i]
+		if comp.TimeSpecifiedFlag && !cmd.SpliceImmediateFlag {
+			comp.PTS = wrapPts(comp.PTS + delta)
+		}
+	}
+	secs := uint32(int64(math.Round(delta)))
+	for i := range cmd.SpliceEvents {
+		evt := &cmd.SpliceEvents[i]
+		if evt.ProgramSpliceFlag && !evt.TimeUnspecified {
+			evt.UTCSpliceTime += secs
+		}
+		for j := range evt.Components {
+			if !evt.Components[j].TimeUnspecified {
+				evt.Components[j].UTCSpliceTime += secs
+			}
+		}
+	}
+	cue.Encode()
+}
+
+/*
+SnapToFrame rounds Command.PTS to the nearest frame boundary at fps,
+re-encodes cue and returns the delta applied in seconds.
+
+	Frame boundaries are counted from PTS 0 in 90k ticks.
+	23.976, 29.97 and 59.94 are taken as 24000/1001, 30000/1001
+	and 60000/1001 so no error builds up over a long PTS.
+	Nothing is changed when cue has no splice time or fps is not positive.
+*/
+func (cue *Cue) SnapToFrame(fps float64) float64 {
+	cmd := cue.Command
+	if cmd == nil || !cmd.TimeSpecifiedFlag || cue.IsImmediate() || fps <= 0 {
+		return 0
+	}
+	num, den := frameRate(fps)
+	ticks := int64(u64(cmd.PTS))
+	// a frame is 90000*den/num ticks
+	step := 90000 * den
+	frame := (ticks*num + step/2) / step
+	snapped := (frame*step + num/2) / num
+	if snapped == ticks {
+		return 0
+	}
+	cmd.PTS = wrapPts(float64(snapped) / 90000.0)
+	cue.Encode()
+	return float64(snapped-ticks) / 90000.0
+}
+
+// frameRate returns fps as the fraction num/den, NTSC rates use a 1001 denominator.
+func frameRate(fps float64) (num, den int64) {
+	for _, rate := range []int64{24, 30, 48, 60, 120} {
+		if math.Abs(fps-float64(rate*1000)/1001.0) < 0.005 {
+			return rate * 1000, 1001
+		}
+	}
+	return int64(math.Round(fps * 1000)), 1000
+}
+
+/*
+EncodedLen returns the number of bytes Encode will return for cue,
+without building the section or changing cue, PTSRaw included.
+
+	A Cue with no InfoSection or Command can not be encoded, it is 0.
+*/
+func (cue *Cue) EncodedLen() int {
+	if cue.InfoSection == nil || cue.Command == nil {
+		return 0
+	}
+	if cue.Ciphertext {
+		// 14 bytes for info section + the encrypted bytes + 4 for crc
+		return 14 + len(cue.Command.RawBytes) + 4
+	}
+	dll := 0
+	for _, dscptr := range cue.Descriptors {
+		// +2 for tag and length
+		dll += dscptr.encodedLen() + 2
+	}
+	// 14 bytes for info section + command + 2 descriptor loop length
+	// + descriptor loop + alignment stuffing + 4 for crc
+	return 14 + cue.Command.encodedLen() + 2 + dll + int(cue.InfoSection.Stuffing) + 4
+}
+
+/*
+MarshalBinary encodes cue like Encode,
+but returns an error instead of writing a field that overflows.
+
+	A Partial Cue is an error, its descriptors were not decoded.
+	A Ciphertext Cue is encoded with its encrypted bytes as they were decoded.
+	An EncryptionAlgorithm more than 63 does not fit in its 6 bits.
+	A descriptor, usually a Segmentation Descriptor with a large MID,
+	may be at most 255 bytes after the tag and length,
+	and section_length at most 4093.
+*/
+func (cue *Cue) MarshalBinary() ([]byte, error) {
+	if cue.Partial {
+		return nil, errors.New("cue was decoded with HeaderOnly, its descriptors were not decoded")
+	}
+	if algo := cue.InfoSection.EncryptionAlgorithm; !algo.Valid() {
+		return nil, fmt.Errorf("encryption algorithm %d is more than 63", uint8(algo))
+	}
+	for i, dscptr := range cue.Descriptors {
+		if n := dscptr.encodedLen(); n > 255 {
+			return nil, fmt.Errorf("descriptor %v tag %#x is %v bytes, more than 255", i, dscptr.Tag, n)
+		}
+	}
+	if n := cue.EncodedLen() - 3; n > 4093 {
+		return nil, fmt.Errorf("section length %v is more than 4093", n)
+	}
+	return cue.Encode(), nil
+}
+
 // Encode Cue currently works for Splice Inserts and Time Signals
 func (cue *Cue) Encode() []byte {
+	return cue.EncodeWith(EncodeOptions{})
+}
+
+/*
+EncodeOptions override values Encode normally computes.
+
+	They write malformed Cues on purpose and are
+	UNSAFE, for conformance testing and reproducing field bugs only.
+*/
+type EncodeOptions struct {
+	SectionLength uint16 // Write this section_length instead of the computed one, if not zero.
+	KeepCrc32     bool   // Write cue.Crc32 as is instead of recomputing it.
+	// PreserveCommandLength writes InfoSection.CommandLength as decoded,
+	// 0xfff included, instead of the length of the encoded command.
+	PreserveCommandLength bool
+}
+
+/*
+EncodeWith encodes cue like Encode, with the overrides in opts.
+
+	UNSAFE: the bytes may not be a valid splice info section,
+	use Encode for anything but testing.
+*/
+func (cue *Cue) EncodeWith(opts EncodeOptions) []byte {
+	if cue.Ciphertext {
+		return cue.encodeCiphertext(opts)
+	}
 	cmdb := cue.Command.Encode()
 	cmdl := len(cmdb)
-	cue.InfoSection.CommandLength = uint16(cmdl)
+	if !opts.PreserveCommandLength {
+		cue.InfoSection.CommandLength = uint16(cmdl)
+	}
 	cue.InfoSection.CommandType = cue.Command.CommandType
+	// rollLoop sets cue.Dll, so it runs before the section length is set.
+	dloop := cue.rollLoop()
+	stuffing := cue.InfoSection.Stuffing
 	// 11 bytes for info section + command + 2 descriptor loop length
-	// + descriptor loop + 4 for crc
-	cue.InfoSection.SectionLength = uint16(11+cmdl+2+4) + cue.Dll
+	// + descriptor loop + alignment stuffing + 4 for crc
+	cue.InfoSection.SectionLength = uint16(11+cmdl+2+4) + cue.Dll + stuffing
+	if opts.SectionLength != 0 {
+		cue.InfoSection.SectionLength = opts.SectionLength
+	}
 	isecb := cue.InfoSection.Encode()
 	be := &bitEncoder{}
 	isecbits := uint(len(isecb) << 3)
 	be.AddBytes(isecb, isecbits)
 	cmdbits := uint(cmdl << 3)
 	be.AddBytes(cmdb, cmdbits)
-	dloop := cue.rollLoop()
 	be.Add(cue.Dll, 16)
 	be.AddBytes(dloop, uint(cue.Dll<<3))
-	cue.Crc32 = cRC32(be.Bites.Bytes())
+	for ; stuffing > 0; stuffing-- {
+		be.Add(0xff, 8)
+	}
+	if !opts.KeepCrc32 {
+		cue.Crc32 = cRC32(be.Bites.Bytes())
+	}
+	be.Add(cue.Crc32, 32)
+	cue.bites = be.Bites.Bytes()
+	return cue.bites
+}
+
+/*
+encodeCiphertext encodes a Ciphertext Cue, the InfoSection
+and the encrypted bytes in Command.RawBytes as they were decoded.
+
+	CommandLength is written as decoded,
+	the encrypted command can not be measured.
+*/
+func (cue *Cue) encodeCiphertext(opts EncodeOptions) []byte {
+	ciphertext := cue.Command.RawBytes
+	cue.InfoSection.CommandType = cue.Command.CommandType
+	// 11 bytes for info section + the encrypted bytes + 4 for crc
+	cue.InfoSection.SectionLength = uint16(11 + len(ciphertext) + 4)
+	if opts.SectionLength != 0 {
+		cue.InfoSection.SectionLength = opts.SectionLength
+	}
+	bites := append(cue.InfoSection.Encode(), ciphertext...)
+	if !opts.KeepCrc32 {
+		cue.Crc32 = cRC32(bites)
+	}
+	be := &bitEncoder{}
 	be.Add(cue.Crc32, 32)
-	return be.Bites.Bytes()
+	crc := be.Bites.FillBytes(make([]byte, 4))
+	cue.bites = append(bites, crc...)
+	return cue.bites
+}
+
+/*
+RefreshCRC recomputes Crc32 and the encoded bytes of cue after a change
+to a field that does not change a length, like InfoSection.PtsAdjustment.
+
+	Only Crc32 and the bytes are updated, the lengths are left as they are.
+	An error is returned, and cue is not changed, when the section length,
+	command length or descriptor loop length would change, use Encode then.
+*/
+func (cue *Cue) RefreshCRC() error {
+	if cue.InfoSection == nil || cue.Command == nil {
+		return errors.New("cue has not been decoded")
+	}
+	c := cue.clone()
+	bites := c.Encode()
+	infosec, was := c.InfoSection, cue.InfoSection
+	switch {
+	case infosec.SectionLength != was.SectionLength:
+		return fmt.Errorf("section length would change from %v to %v", was.SectionLength, infosec.SectionLength)
+	case infosec.CommandLength != was.CommandLength:
+		return fmt.Errorf("command length would change from %v to %v", was.CommandLength, infosec.CommandLength)
+	case c.Dll != cue.Dll:
+		return fmt.Errorf("descriptor loop length would change from %v to %v", cue.Dll, c.Dll)
+	}
+	cue.Crc32 = c.Crc32
+	cue.bites = bites
+	return nil
+}
+
+/*
+Canonicalize re-encodes cue in the canonical form of the library
+and decodes it again.
+
+	Reserved bits are set to 1, stuffing is dropped,
+	and lengths and the Crc32 are recomputed.
+	Semantic fields are not changed, so Cues from different encoders
+	can be compared by the bytes of Encode after Canonicalize.
+*/
+func (cue *Cue) Canonicalize() {
+	pd := cue.PacketData
+	cue.InfoSection.Stuffing = 0
+	bites := cue.Encode()
+	*cue = Cue{}
+	cue.decodeBytes(&Decoder{}, bites)
+	cue.PacketData = pd
+}
+
+/*
+PadTo sets InfoSection.Stuffing so cue encodes to size bytes
+and re-encodes it, the crc32 covers the stuffing.
+
+	An error is returned when cue is already more than size bytes
+	without stuffing, or size is more than the 4096 bytes of a section.
+*/
+func (cue *Cue) PadTo(size int) error {
+	if size > 4096 {
+		return fmt.Errorf("size %v is more than 4096", size)
+	}
+	cue.InfoSection.Stuffing = 0
+	n := cue.EncodedLen()
+	if n > size {
+		return fmt.Errorf("cue is %v bytes, more than %v", n, size)
+	}
+	cue.InfoSection.Stuffing = uint16(size - n)
+	cue.Encode()
+	return nil
 }
 
 // Encode2B64 Encodes cue and returns Base64 string
@@ -133,11 +666,60 @@ func (cue *Cue) Encode2B64() string {
 	return encB64(cue.Encode())
 }
 
+/*
+ReEncodeB64 returns the base64 string cue was decoded from
+when encoding cue gives the same bytes, otherwise it returns Encode2B64.
+cue is not changed.
+*/
+func (cue *Cue) ReEncodeB64() string {
+	bites := cue.clone().Encode()
+	orig, err := base64.StdEncoding.DecodeString(cue.str)
+	if err == nil && bytes.Equal(orig, bites) {
+		return cue.str
+	}
+	return encB64(bites)
+}
+
+/*
+ReEncodeHex returns the hex string cue was decoded from
+when encoding cue gives the same bytes, otherwise it returns Encode2Hex.
+cue is not changed.
+*/
+func (cue *Cue) ReEncodeHex() string {
+	c := cue.clone()
+	orig, ok := tolerantHex(cue.str)
+	if ok && bytes.Equal(orig, c.Encode()) {
+		return cue.str
+	}
+	return c.Encode2Hex()
+}
+
+/*
+Encodings encodes cue once and returns the bytes,
+with the same strings as Encode2B64 and Encode2Hex.
+*/
+func (cue *Cue) Encodings() ([]byte, string, string) {
+	bites := cue.Encode()
+	return bites, encB64(bites), "0x" + hex.EncodeToString(bites)
+}
+
 // Encode2Hex encodes cue and returns as a hex string
 func (cue *Cue) Encode2Hex() string {
+	return fmt.Sprintf("0x%v", cue.Encode2BigInt().Text(16))
+}
+
+/*
+Encode2BigInt encodes cue and returns the bytes as a *big.Int.
+
+	A big.Int holds only the magnitude, leading zero bytes are lost.
+	An encoded Cue starts with the 0xfc table id, so it has none,
+	and Cue.Decode takes the value back as a string
+	from the big.Int String or Text methods.
+*/
+func (cue *Cue) Encode2BigInt() *big.Int {
 	b := new(big.Int)
 	b.SetBytes(cue.Encode())
-	return fmt.Sprintf("0x%v", b.Text(16))
+	return b
 }
 
 // used by Six2Five to convert a time signal to a splice insert
@@ -156,7 +738,6 @@ func (cue *Cue) mkSpliceInsert() {
 	cue.Command.AvailExpected = 0
 	if cue.Command.PTS > 0.0 {
 		cue.Command.TimeSpecifiedFlag = true
-		cue.Command.PTS = cue.Command.PTS
 	}
 }
 
@@ -169,7 +750,8 @@ func (cue *Cue) Six2Five() string {
 	segStops := []uint16{0x23, 0x31, 0x33, 0x35, 0x37, 0x39, 0x3b, 0x3d, 0x3f, 0x45, 0x47}
 	if cue.InfoSection.CommandType == 6 {
 		for _, dscptr := range cue.Descriptors {
-			if dscptr.Tag == 2 {
+			// 0x00 Not Indicated is neither a start nor a stop.
+			if dscptr.Tag == 2 && dscptr.SegmentationTypeID != 0x00 {
 				//value, _ := strconv.ParseInt(hex, 16, 64)
 				cue.Command.SpliceEventID = uint32(hex2Int(dscptr.SegmentationEventID))
 				if isIn(segStarts, uint16(dscptr.SegmentationTypeID)) {
@@ -191,6 +773,633 @@ func (cue *Cue) Six2Five() string {
 	return encB64(cue.Encode())
 }
 
+/*
+MakeReturn returns the return to network Cue
+that closes cue at ptsSecs.
+
+	A Splice Insert has OutOfNetworkIndicator set to false,
+	and each component PTS set to ptsSecs in component mode.
+	A Time Signal has each Segmentation Descriptor
+	start type swapped for its paired end type, and only those are kept.
+	The start types of Segmentation Descriptors on a Splice Insert
+	are swapped too, its other descriptors are kept.
+	A cancelled Splice Insert has no return.
+*/
+func (cue *Cue) MakeReturn(ptsSecs float64) (*Cue, error) {
+	if cue.Command == nil || cue.InfoSection == nil {
+		return nil, errors.New("cue has not been decoded")
+	}
+	ret := cue.clone()
+	ret.PacketData = nil
+	switch cue.Command.CommandType {
+	case 0x5:
+		if cue.Command.SpliceEventCancelIndicator {
+			return nil, errors.New("splice insert is cancelled")
+		}
+		if !cue.Command.OutOfNetworkIndicator {
+			return nil, errors.New("splice insert is not out of network")
+		}
+		ret.Command.OutOfNetworkIndicator = false
+		ret.Command.DurationFlag = false
+		ret.Command.BreakAutoReturn = false
+		ret.Command.BreakDuration = 0.0
+		ret.Command.SpliceImmediateFlag = false
+		for i := range ret.Command.Components {
+			ret.Command.Components[i].TimeSpecifiedFlag = true
+			ret.Command.Components[i].PTS = ptsSecs
+		}
+		ret.EachSegmentation(func(dscptr *Descriptor) {
+			dscptr.segmentationEnd()
+		})
+	case 0x6:
+		var dscptrs []Descriptor
+		for _, dscptr := range ret.Descriptors {
+			if dscptr.Tag == 0x2 && dscptr.segmentationEnd() {
+				dscptrs = append(dscptrs, dscptr)
+			}
+		}
+		if len(dscptrs) == 0 {
+			return nil, errors.New("time signal has no segmentation start descriptor")
+		}
+		ret.Descriptors = dscptrs
+	default:
+		return nil, fmt.Errorf("command type %#x has no return", cue.Command.CommandType)
+	}
+	ret.Command.TimeSpecifiedFlag = true
+	ret.Command.PTS = ptsSecs
+	ret.Encode()
+	return ret, nil
+}
+
+// segmentationEnd swaps a segmentation start type for its paired end type, it returns false for other types.
+func (dscptr *Descriptor) segmentationEnd() bool {
+	stop, ok := segPairs[dscptr.SegmentationTypeID]
+	if !ok || dscptr.SegmentationEventCancelIndicator {
+		return false
+	}
+	dscptr.SegmentationTypeID = stop
+	dscptr.SegmentationMessage = table22[stop]
+	dscptr.SegmentationDurationFlag = false
+	dscptr.SegmentationDuration = 0.0
+	return true
+}
+
+/*
+SetFixedEnd sets the duration of cue so the break ends at endPTS
+and re-encodes it.
+
+	The duration is endPTS minus Command.PTS, wrapped at 33 bits.
+	A Splice Insert gets DurationFlag and BreakDuration set
+	and BreakAutoReturn cleared, the return comes from an in Cue.
+	A Time Signal gets the SegmentationDuration of its segmentation start descriptors set.
+	An error is returned when cue has no splice time.
+*/
+func (cue *Cue) SetFixedEnd(endPTS float64) error {
+	cmd := cue.Command
+	if cmd == nil || !cmd.TimeSpecifiedFlag || cue.IsImmediate() {
+		return errors.New("cue has no splice time")
+	}
+	duration := wrapPts(endPTS - cmd.PTS)
+	switch cmd.CommandType {
+	case 0x5:
+		cmd.DurationFlag = true
+		cmd.BreakDuration = duration
+		cmd.BreakAutoReturn = false
+	case 0x6:
+		starts := 0
+		cue.EachSegmentation(func(dscptr *Descriptor) {
+			if _, ok := segPairs[dscptr.SegmentationTypeID]; ok {
+				dscptr.SegmentationDurationFlag = true
+				dscptr.SegmentationDuration = duration
+				starts++
+			}
+		})
+		if starts == 0 {
+			return errors.New("time signal has no segmentation start descriptor")
+		}
+	default:
+		return fmt.Errorf("command type %#x has no duration", cmd.CommandType)
+	}
+	cue.Encode()
+	return nil
+}
+
+/*
+ValidPair returns true when in closes the avail out opens,
+otherwise the reason they do not pair.
+
+	Splice Inserts pair by SpliceEventID and OutOfNetworkIndicator,
+	Time Signals by a segmentation start descriptor in out
+	and its end type in in, with the same SegmentationEventID.
+	When both have a splice time, in must be after out,
+	within half the 33 bit PTS range to allow for a wrap.
+*/
+func ValidPair(out, in *Cue) (bool, string) {
+	if out.Command == nil || in.Command == nil {
+		return false, "cue has not been decoded"
+	}
+	if out.Command.CommandType != in.Command.CommandType {
+		return false, fmt.Sprintf("command types %#x and %#x differ", out.Command.CommandType, in.Command.CommandType)
+	}
+	switch out.Command.CommandType {
+	case 0x5:
+		if out.Command.SpliceEventID != in.Command.SpliceEventID {
+			return false, fmt.Sprintf("splice event ids %v and %v differ", out.Command.SpliceEventID, in.Command.SpliceEventID)
+		}
+		if !out.Command.OutOfNetworkIndicator {
+			return false, "out is not out of network"
+		}
+		if in.Command.OutOfNetworkIndicator {
+			return false, "in is out of network"
+		}
+	case 0x6:
+		if !segmentationPair(out, in) {
+			return false, "no segmentation start in out has its end type and event id in in"
+		}
+	default:
+		return false, fmt.Sprintf("command type %#x does not pair", out.Command.CommandType)
+	}
+	if out.IsImmediate() || in.IsImmediate() || !out.Command.TimeSpecifiedFlag || !in.Command.TimeSpecifiedFlag {
+		return true, ""
+	}
+	outPts, inPts := out.adjustedTicks(), in.adjustedTicks()
+	diff := (inPts - outPts + rollOver) % rollOver
+	if diff == 0 || diff >= rollOver/2 {
+		return false, fmt.Sprintf("in pts %v is not after out pts %v", mk90k(uint64(inPts)), mk90k(uint64(outPts)))
+	}
+	return true, ""
+}
+
+/*
+Overlaps returns true when the avails of out cues a and b overlap,
+as nested breaks or an encoder error do.
+
+	Each avail is the AvailWindow of its Cue, the splice time plus
+	PtsAdjustment to that plus the break or segmentation duration.
+	b may start before or after a, within half the 33 bit PTS range
+	to allow for a wrap. An avail that starts as the other ends does not overlap.
+	An error is returned when a or b has no splice time or duration.
+*/
+func Overlaps(a, b *Cue) (bool, error) {
+	aStart, aDur, err := availTicks(a)
+	if err != nil {
+		return false, fmt.Errorf("cue a: %v", err)
+	}
+	bStart, bDur, err := availTicks(b)
+	if err != nil {
+		return false, fmt.Errorf("cue b: %v", err)
+	}
+	// d is where b starts relative to a
+	d := (bStart - aStart + rollOver) % rollOver
+	if d >= rollOver/2 {
+		d -= rollOver
+	}
+	return d < aDur && d+bDur > 0, nil
+}
+
+// availTicks returns the start and duration of the AvailWindow of cue in 90k ticks.
+func availTicks(cue *Cue) (start, duration int64, err error) {
+	s, e, ok := cue.AvailWindow()
+	if !ok {
+		return 0, 0, errors.New("no splice time or duration")
+	}
+	start = int64(u64(s))
+	duration = (int64(u64(e)) - start + rollOver) % rollOver
+	return start, duration, nil
+}
+
+// segmentationPair returns true if in has the end of a segmentation start in out.
+func segmentationPair(out, in *Cue) bool {
+	for _, start := range out.Descriptors {
+		stop, ok := segPairs[start.SegmentationTypeID]
+		if start.Tag != 0x2 || !ok {
+			continue
+		}
+		for _, end := range in.Descriptors {
+			if end.Tag == 0x2 && end.SegmentationTypeID == stop && end.SegmentationEventID == start.SegmentationEventID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// adjustedTicks returns Command.PTS plus InfoSection.PtsAdjustment in 90k ticks, wrapped at 33 bits.
+func (cue *Cue) adjustedTicks() int64 {
+	var adj float64
+	if cue.InfoSection != nil {
+		adj = cue.InfoSection.PtsAdjustment
+	}
+	return int64(u64(wrapPts(cue.Command.PTS + adj)))
+}
+
+/*
+EffectivePTS returns the splice time of cue, Command.PTS plus
+InfoSection.PtsAdjustment, wrapped at 33 bits.
+
+	Neither field is changed. ok is false when cue has no splice time.
+*/
+func (cue *Cue) EffectivePTS() (pts float64, ok bool) {
+	cmd := cue.Command
+	if cmd == nil || !cmd.TimeSpecifiedFlag || cue.IsImmediate() {
+		return 0, false
+	}
+	return mk90k(uint64(cue.adjustedTicks())), true
+}
+
+/*
+RenumberEvents gives the event ids of cues new sequential ids from start
+and returns the old to new mapping.
+
+	Splice Insert and Splice Schedule splice_event_ids
+	and segmentation_event_ids share one mapping,
+	so an out and its in keep the same new id.
+	The two id spaces are merged: an old id used as both
+	a splice_event_id and a segmentation_event_id gets one new id for both,
+	and is one key in the returned map.
+	Ids are numbered in the order they are first seen,
+	and each Cue is re-encoded.
+*/
+func RenumberEvents(cues []*Cue, start uint32) map[uint32]uint32 {
+	ids := map[uint32]uint32{}
+	renumber := func(old uint32) uint32 {
+		id, ok := ids[old]
+		if !ok {
+			id = start + uint32(len(ids))
+			ids[old] = id
+		}
+		return id
+	}
+	for _, cue := range cues {
+		if cue.Command != nil {
+			switch cue.Command.CommandType {
+			case 0x4:
+				for i := range cue.Command.SpliceEvents {
+					evt := &cue.Command.SpliceEvents[i]
+					evt.SpliceEventID = renumber(evt.SpliceEventID)
+				}
+			case 0x5:
+				cue.Command.SpliceEventID = renumber(cue.Command.SpliceEventID)
+			}
+		}
+		cue.EachSegmentation(func(dscptr *Descriptor) {
+			id := renumber(hexValue(dscptr.SegmentationEventID))
+			dscptr.SegmentationEventID = hexID(uint64(id), 32)
+		})
+		cue.Encode()
+	}
+	return ids
+}
+
+/*
+Fingerprint returns a sha256 hex digest of the semantic fields of cue
+for spotting retransmitted Cues.
+
+	Crc32, PacketData and Warnings are ignored.
+	InfoSection.PtsAdjustment is included when withPtsAdjustment is true.
+*/
+func (cue *Cue) Fingerprint(withPtsAdjustment bool) string {
+	c := cue.clone()
+	c.Crc32 = 0
+	c.PacketData = nil
+	c.Warnings = nil
+	if c.InfoSection != nil && !withPtsAdjustment {
+		c.InfoSection.PtsAdjustment = 0.0
+		c.InfoSection.PtsAdjustmentRaw = 0
+	}
+	jason, err := json.Marshal(c)
+	chk(err)
+	if c.Ciphertext && c.Command != nil {
+		// the encrypted bytes are all there is of the command and descriptors
+		jason = append(jason, c.Command.RawBytes...)
+	}
+	sum := sha256.Sum256(jason)
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+Hash64 returns the 64 bit FNV-1a hash of the canonical bytes of cue,
+the bytes Encode returns. cue is not changed.
+
+	The hash is over exact bytes, so it is cheap and stable across runs,
+	but unlike Fingerprint a change to any field changes it.
+*/
+func (cue *Cue) Hash64() uint64 {
+	h := fnv.New64a()
+	h.Write(cue.clone().Encode())
+	return h.Sum64()
+}
+
+// clone returns a copy of cue that shares no structs with it.
+func (cue *Cue) clone() *Cue {
+	c := *cue
+	if cue.InfoSection != nil {
+		infosec := *cue.InfoSection
+		c.InfoSection = &infosec
+	}
+	if cue.Command != nil {
+		cmd := *cue.Command
+		cmd.Components = append([]SpliceComponent(nil), cmd.Components...)
+		cmd.PrivateBytes = append([]byte(nil), cmd.PrivateBytes...)
+		cmd.RawBytes = append([]byte(nil), cmd.RawBytes...)
+		cmd.SpliceEvents = nil
+		for _, evt := range cue.Command.SpliceEvents {
+			evt.Components = append([]ScheduleComponent(nil), evt.Components...)
+			cmd.SpliceEvents = append(cmd.SpliceEvents, evt)
+		}
+		c.Command = &cmd
+	}
+	if cue.PacketData != nil {
+		pd := *cue.PacketData
+		c.PacketData = &pd
+	}
+	c.Descriptors = nil
+	for _, dscptr := range cue.Descriptors {
+		c.Descriptors = append(c.Descriptors, dscptr.copy())
+	}
+	return &c
+}
+
+/*
+IsImmediate returns true when cue means splice now.
+
+	A Splice Insert with SpliceImmediateFlag set
+	or a Time Signal without TimeSpecifiedFlag is immediate,
+	other commands never are.
+*/
+func (cue *Cue) IsImmediate() bool {
+	if cue.Command == nil {
+		return false
+	}
+	switch cue.Command.CommandType {
+	case 0x5:
+		return !cue.Command.SpliceEventCancelIndicator && cue.Command.SpliceImmediateFlag
+	case 0x6:
+		return !cue.Command.TimeSpecifiedFlag
+	}
+	return false
+}
+
+/*
+InTier returns true when a device in deviceTier should act on cue.
+
+	The 12 bit tier 0xfff means every tier acts on cue,
+	any other tier only matches the same deviceTier.
+*/
+func (cue *Cue) InTier(deviceTier uint16) bool {
+	tier := uint16(0xfff)
+	if cue.InfoSection != nil && cue.InfoSection.Tier != "" {
+		tier = uint16(hexValue(cue.InfoSection.Tier)) & 0xfff
+	}
+	return tier == 0xfff || tier == deviceTier&0xfff
+}
+
+/*
+Encrypted returns true when the encrypted_packet flag of cue is set.
+
+	The Command and Descriptors of an encrypted Cue are ciphertext,
+	PtsAdjustment, CwIndex and Tier are in the clear.
+*/
+func (cue *Cue) Encrypted() bool {
+	return cue.InfoSection != nil && cue.InfoSection.EncryptedPacket
+}
+
+// IsNoOp returns true for a Splice Null or Time Signal without descriptors.
+func (cue *Cue) IsNoOp() bool {
+	if cue.Command == nil || len(cue.Descriptors) > 0 {
+		return false
+	}
+	return cue.Command.CommandType == 0x0 || cue.Command.CommandType == 0x6
+}
+
+/*
+AvailWindow returns the start and end, in seconds, of the avail cue opens.
+
+	start is Command.PTS plus InfoSection.PtsAdjustment,
+	end is start plus the break duration of an out Splice Insert,
+	or the duration of the first segmentation start descriptor
+	of a Time Signal. Both are wrapped at 33 bits.
+	ok is false when cue has no splice time or no duration.
+
+	Without BreakAutoReturn end is informational,
+	the return is the splice time of a later in Cue.
+*/
+func (cue *Cue) AvailWindow() (start, end float64, ok bool) {
+	cmd := cue.Command
+	if cmd == nil || cue.InfoSection == nil || !cmd.TimeSpecifiedFlag || cue.IsImmediate() {
+		return 0, 0, false
+	}
+	var duration float64
+	switch cmd.CommandType {
+	case 0x5:
+		if !cmd.OutOfNetworkIndicator || !cmd.DurationFlag {
+			return 0, 0, false
+		}
+		duration = cmd.BreakDuration
+	case 0x6:
+		for _, dscptr := range cue.Descriptors {
+			_, isStart := segPairs[dscptr.SegmentationTypeID]
+			if dscptr.HasDuration() && isStart {
+				duration = dscptr.SegmentationDuration
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return 0, 0, false
+		}
+	default:
+		return 0, 0, false
+	}
+	start = wrapPts(cmd.PTS + cue.InfoSection.PtsAdjustment)
+	end = wrapPts(start + duration)
+	return start, end, true
+}
+
+/*
+OffsetFrom returns the seconds from baseMediaTime90k, the base media decode time
+of a segment in seconds on the 90k clock, to the splice time of cue,
+the offset for an emsg presentation_time.
+
+	The splice time is Command.PTS plus InfoSection.PtsAdjustment,
+	baseMediaTime90k is wrapped at 33 bits, and the difference is taken
+	across a wrap, within half the 33 bit range either way,
+	so a splice time before baseMediaTime90k is negative.
+	ok is false when cue has no splice time.
+*/
+func (cue *Cue) OffsetFrom(baseMediaTime90k float64) (offset float64, ok bool) {
+	cmd := cue.Command
+	if cmd == nil || !cmd.TimeSpecifiedFlag || cue.IsImmediate() {
+		return 0, false
+	}
+	base := int64(u64(wrapPts(baseMediaTime90k)))
+	diff := (cue.adjustedTicks() - base + rollOver) % rollOver
+	if diff >= rollOver/2 {
+		diff -= rollOver
+	}
+	return float64(diff) / 90000.0, true
+}
+
+// AddDescriptor appends dscptr to cue.Descriptors and re-encodes cue.
+func (cue *Cue) AddDescriptor(dscptr Descriptor) {
+	cue.Descriptors = append(cue.Descriptors, dscptr.copy())
+	cue.Encode()
+}
+
+// EachDescriptor calls fn with a pointer to each descriptor in cue with tag.
+func (cue *Cue) EachDescriptor(tag uint8, fn func(*Descriptor)) {
+	for i := range cue.Descriptors {
+		if cue.Descriptors[i].Tag == tag {
+			fn(&cue.Descriptors[i])
+		}
+	}
+}
+
+// EachSegmentation calls fn with a pointer to each segmentation descriptor in cue.
+func (cue *Cue) EachSegmentation(fn func(*Descriptor)) {
+	cue.EachDescriptor(2, fn)
+}
+
+// PolicyDefault is what MatchesPolicy returns for a Cue without Segmentation Descriptors.
+var PolicyDefault = true
+
+/*
+MatchesPolicy returns true when the SegmentationTypeID
+of every Segmentation Descriptor in cue is in allowed.
+
+	A Cue without Segmentation Descriptors returns PolicyDefault.
+*/
+func (cue *Cue) MatchesPolicy(allowed []uint8) bool {
+	found := false
+	matches := true
+	cue.EachSegmentation(func(dscptr *Descriptor) {
+		found = true
+		ok := false
+		for _, typeID := range allowed {
+			if dscptr.SegmentationTypeID == typeID {
+				ok = true
+				break
+			}
+		}
+		matches = matches && ok
+	})
+	if !found {
+		return PolicyDefault
+	}
+	return matches
+}
+
+/*
+InsertableTypes are the segmentation type ids IsInsertable
+takes as an opportunity to insert an ad.
+
+	0x34 Provider Placement Opportunity Start
+	0x36 Distributor Placement Opportunity Start
+
+	Entries may be added or deleted to match local practice,
+	do it before decoding starts, InsertableTypes is not safe to change concurrently.
+*/
+var InsertableTypes = map[uint8]bool{
+	0x34: true,
+	0x36: true,
+}
+
+/*
+IsInsertable returns true when cue is an opportunity to insert an ad,
+for deciding whether to call an ad server.
+
+	A Splice Insert is insertable when it is out of network with a duration,
+	any other cue when a Segmentation Descriptor has a type in InsertableTypes.
+	Cancelled events are never insertable.
+*/
+func (cue *Cue) IsInsertable() bool {
+	cmd := cue.Command
+	if cmd != nil && cmd.CommandType == 0x5 {
+		return !cmd.SpliceEventCancelIndicator && cmd.OutOfNetworkIndicator && cmd.DurationFlag
+	}
+	for _, dscptr := range cue.Descriptors {
+		if dscptr.Tag == 0x2 && !dscptr.SegmentationEventCancelIndicator && InsertableTypes[dscptr.SegmentationTypeID] {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+SplitSegmentations returns a Cue for each Segmentation Descriptor of cue,
+in order, for consumers that take one Segmentation Descriptor a Cue.
+
+	Each Cue is an encoded copy of cue with only that descriptor,
+	the InfoSection and Command are the same in each.
+	cue is not changed.
+*/
+func (cue *Cue) SplitSegmentations() []*Cue {
+	var cues []*Cue
+	for _, dscptr := range cue.Descriptors {
+		if dscptr.Tag != 0x2 {
+			continue
+		}
+		c := cue.clone()
+		c.Descriptors = []Descriptor{dscptr.copy()}
+		c.Warnings = nil
+		c.Encode()
+		cues = append(cues, c)
+	}
+	return cues
+}
+
+/*
+SegmentationStart returns the first Segmentation Descriptor in cue
+with a start type, a key of segPairs, wherever it is in cue.Descriptors,
+or nil when there is none.
+*/
+func (cue *Cue) SegmentationStart() *Descriptor {
+	return cue.findSegmentation(func(typeID uint8) bool {
+		_, ok := segPairs[typeID]
+		return ok
+	})
+}
+
+/*
+SegmentationStop returns the first Segmentation Descriptor in cue
+with an end type, a value of segPairs, wherever it is in cue.Descriptors,
+or nil when there is none.
+
+	Stops sent before their start are found the same.
+*/
+func (cue *Cue) SegmentationStop() *Descriptor {
+	return cue.findSegmentation(func(typeID uint8) bool {
+		for _, end := range segPairs {
+			if typeID == end {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// findSegmentation returns the first Segmentation Descriptor in cue with a type id that match accepts.
+func (cue *Cue) findSegmentation(match func(uint8) bool) *Descriptor {
+	for i := range cue.Descriptors {
+		dscptr := &cue.Descriptors[i]
+		if dscptr.Tag == 0x2 && !dscptr.SegmentationEventCancelIndicator && match(dscptr.SegmentationTypeID) {
+			return dscptr
+		}
+	}
+	return nil
+}
+
+// UPIDs returns the Upids of every Segmentation Descriptor in cue, MIDs are flattened and UpidBytes are parsed with ParseUPID.
+func (cue *Cue) UPIDs() []UPID {
+	var upids []UPID
+	cue.EachSegmentation(func(dscptr *Descriptor) {
+		if upid, _ := dscptr.ParseUPID(); upid != nil {
+			upids = upid.flatten(upids, dscptr.SegmentationEventID, dscptr.SegmentationUpidType)
+		}
+	})
+	return upids
+}
+
 // initialize and return a *Cue
 func NewCue() *Cue {
 	cue := &Cue{}
@@ -0,0 +1,762 @@
+package cuei_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+// timeSignal is a Time Signal with no descriptors.
+const timeSignal = "/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q=="
+
+// legacyAvail is a Splice Insert with only an Avail Descriptor, as legacy cable systems send.
+const legacyAvail = "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+
+// withDescriptors returns the timeSignal Cue carrying dscptrs.
+func withDescriptors(dscptrs ...cuei.Descriptor) *cuei.Cue {
+	cue := cuei.NewCue()
+	cue.Decode(timeSignal)
+	cue.Descriptors = dscptrs
+	cue.Encode()
+	return cue
+}
+
+// roundTrip encodes cue and decodes the bytes into a new Cue.
+func roundTrip(t *testing.T, cue *cuei.Cue) *cuei.Cue {
+	t.Helper()
+	cue2, err := cuei.NewDecoder().Decode(cue.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cue2.Dll != cue.Dll {
+		t.Errorf("DescriptorLoopLength is %v, want %v", cue2.Dll, cue.Dll)
+	}
+	return cue2
+}
+
+// segmentation returns a program mode Segmentation Descriptor.
+func segmentation(typeID uint8) cuei.Descriptor {
+	return cuei.Descriptor{
+		Tag:                       0x2,
+		SegmentationEventID:       "0x4800008f",
+		ProgramSegmentationFlag:   true,
+		DeliveryNotRestrictedFlag: true,
+		SegmentationTypeID:        typeID,
+	}
+}
+
+// withCommand returns the timeSignal Cue with its Command replaced by cmd.
+func withCommand(cmd *cuei.Command) *cuei.Cue {
+	cue := cuei.NewCue()
+	cue.Decode(timeSignal)
+	cue.Command = cmd
+	cue.Encode()
+	return cue
+}
+
+func TestCanonicalize(t *testing.T) {
+	// testData with the reserved bits of the info section and splice insert cleared
+	data := "0xfc002f000000000000fffff014054800008f00e0fe7369c02efe0052ccf500000000" +
+		"000a0008435545490000013562dba30a"
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	before := cue.Command.Summary()
+	cue.Canonicalize()
+	if cue.InfoSection.Reserved != "0x3" {
+		t.Errorf("Reserved is %v", cue.InfoSection.Reserved)
+	}
+	if cue.Command.Summary() != before {
+		t.Errorf("command changed to %v from %v", cue.Command.Summary(), before)
+	}
+	if cue.Encode2B64() != "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=" {
+		t.Errorf("canonical form is %v", cue.Encode2B64())
+	}
+
+	restricted := segmentation(0x30)
+	restricted.DeliveryNotRestrictedFlag = false
+	restricted.DeviceRestrictions = "Restrict Group 1"
+	cue = withDescriptors(restricted)
+	cue.Canonicalize()
+	if cue.Descriptors[0].DeviceRestrictions != "Restrict Group 1" {
+		t.Errorf("DeviceRestrictions changed to %v", cue.Descriptors[0].DeviceRestrictions)
+	}
+}
+
+func TestEachSegmentation(t *testing.T) {
+	avail := cuei.Descriptor{Tag: 0x0, ProviderAvailID: 7}
+	cue := withDescriptors(segmentation(0x34), avail, segmentation(0x36))
+	cue.EachSegmentation(func(dscptr *cuei.Descriptor) {
+		dscptr.SegmentationTypeID++
+	})
+	got := []uint8{cue.Descriptors[0].SegmentationTypeID, cue.Descriptors[2].SegmentationTypeID}
+	if got[0] != 0x35 || got[1] != 0x37 {
+		t.Errorf("segmentation type ids are %#x, want 0x35 and 0x37", got)
+	}
+	var avails int
+	cue.EachDescriptor(0x0, func(dscptr *cuei.Descriptor) {
+		avails++
+	})
+	if avails != 1 {
+		t.Errorf("EachDescriptor visited %v avail descriptors, want 1", avails)
+	}
+}
+
+func TestAvailWindow(t *testing.T) {
+	out := cuei.NewCue()
+	out.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	start, end, ok := out.AvailWindow()
+	if !ok || start != 21514.559088 || end != 21574.852655 {
+		t.Errorf("splice insert window is %v to %v (%v)", start, end, ok)
+	}
+
+	dscptr := segmentation(0x34)
+	dscptr.SegmentationDurationFlag = true
+	dscptr.SegmentationDuration = 30.0
+	ts := withDescriptors(dscptr)
+	ts.Command.PTS = 95420.0 // the window crosses the 33 bit wrap at 95443.717689
+	ts.InfoSection.PtsAdjustment = 1.0
+	start, end, ok = ts.AvailWindow()
+	if !ok || start != 95421.0 || end != 7.282311 {
+		t.Errorf("time signal window is %v to %v (%v)", start, end, ok)
+	}
+
+	ts.Descriptors[0].SegmentationDurationFlag = false
+	if _, _, ok = ts.AvailWindow(); ok {
+		t.Error("window without a duration is ok")
+	}
+}
+
+func TestEncodedLen(t *testing.T) {
+	cues := []*cuei.Cue{
+		withCommand(&cuei.Command{CommandType: 0x0}),
+		withDescriptors(),
+		withDescriptors(segmentation(0x34), cuei.Descriptor{Tag: 0x0, ProviderAvailID: 7}),
+		withCommand(&cuei.Command{CommandType: 0x4, SpliceEvents: []cuei.SpliceEvent{
+			{SpliceEventID: 1, ProgramSpliceFlag: true, UTCSpliceTime: 1000},
+		}}),
+	}
+	for _, data := range []string{
+		"/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=",
+		"/DCtAAAAAAAAAP/wBQb+Tq9DwQCXAixDVUVJCUvhcH+fAR1QQ1IxXzEyMTYyMTE0MDBXQUJDUkFDSEFFTFJBWSEBAQIsQ1VFSQlL4W9/nwEdUENSMV8xMjE2MjExNDAwV0FCQ1JBQ0hBRUxSQVkRAQECGUNVRUkJTBwVf58BClRLUlIxNjA4NEEQAQECHkNVRUkJTBwWf98AA3clYAEKVEtSUjE2MDg0QSABAdHBXYA=",
+	} {
+		cue := cuei.NewCue()
+		cue.Decode(data)
+		cues = append(cues, cue)
+	}
+	for _, cue := range cues {
+		want := cue.EncodedLen()
+		if got := len(cue.Encode()); got != want {
+			t.Errorf("command type %#x: EncodedLen is %v, Encode returned %v bytes", cue.Command.CommandType, want, got)
+		}
+	}
+}
+
+func TestEncodeWith(t *testing.T) {
+	cue := withDescriptors()
+	bites := cue.EncodeWith(cuei.EncodeOptions{SectionLength: 0x20})
+	if bites[2] != 0x20 {
+		t.Errorf("section_length is %#x, want 0x20", bites[2])
+	}
+	cue.Crc32 = 0
+	bites = cue.EncodeWith(cuei.EncodeOptions{KeepCrc32: true})
+	if crc := bites[len(bites)-4:]; !bytes.Equal(crc, []byte{0, 0, 0, 0}) {
+		t.Errorf("crc32 is %x, want the kept zero", crc)
+	}
+	if cue.Encode2B64() != timeSignal {
+		t.Errorf("Encode after EncodeWith is %v", cue.Encode2B64())
+	}
+}
+
+func TestReEncode(t *testing.T) {
+	upper := "0XFC301600000000000000FFF00506FE00A98AC700000B3BAED9"
+	cue := cuei.NewCue()
+	cue.Decode(upper)
+	if got := cue.ReEncodeHex(); got != upper {
+		t.Errorf("ReEncodeHex is %v, want %v", got, upper)
+	}
+	if got := cue.ReEncodeB64(); got != timeSignal {
+		t.Errorf("ReEncodeB64 of a hex cue is %v, want %v", got, timeSignal)
+	}
+	cue.Command.PTS += 1.0
+	if got := cue.ReEncodeHex(); got == upper {
+		t.Error("ReEncodeHex returned the original after a change")
+	}
+	cue = cuei.NewCue()
+	cue.Decode(timeSignal)
+	if cue.ReEncodeB64() != timeSignal {
+		t.Errorf("ReEncodeB64 is %v, want %v", cue.ReEncodeB64(), timeSignal)
+	}
+}
+
+func TestValidPair(t *testing.T) {
+	out := cuei.NewCue()
+	out.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	in, err := out.MakeReturn(out.Command.PTS + out.Command.BreakDuration)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, reason := cuei.ValidPair(out, in); !ok {
+		t.Errorf("splice insert pair: %v", reason)
+	}
+	if ok, _ := cuei.ValidPair(in, out); ok {
+		t.Error("reversed splice insert pair is valid")
+	}
+	early, _ := out.MakeReturn(out.Command.PTS - 1.0)
+	if ok, reason := cuei.ValidPair(out, early); ok || !strings.Contains(reason, "not after") {
+		t.Errorf("early in: %v %v", ok, reason)
+	}
+
+	// the in is after the 33 bit wrap.
+	start := withDescriptors(segmentation(0x34))
+	start.Command.PTS = 95440.0
+	end, err := start.MakeReturn(5.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, reason := cuei.ValidPair(start, end); !ok {
+		t.Errorf("time signal pair across the wrap: %v", reason)
+	}
+	end.Descriptors[0].SegmentationEventID = "0x1"
+	if ok, _ := cuei.ValidPair(start, end); ok {
+		t.Error("time signal pair with different event ids is valid")
+	}
+}
+
+func TestRenumberEvents(t *testing.T) {
+	out := cuei.NewCue()
+	out.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	in, _ := out.MakeReturn(out.Command.PTS + 60.0)
+	seg := segmentation(0x34)
+	seg.SegmentationEventID = "0x10"
+	start := withDescriptors(seg)
+	end, _ := start.MakeReturn(200.0)
+	ids := cuei.RenumberEvents([]*cuei.Cue{out, start, in, end}, 100)
+	if len(ids) != 2 || ids[0x4800008f] != 100 || ids[0x10] != 101 {
+		t.Fatalf("ids are %v", ids)
+	}
+	if out.Command.SpliceEventID != 100 || in.Command.SpliceEventID != 100 {
+		t.Errorf("splice event ids are %v and %v", out.Command.SpliceEventID, in.Command.SpliceEventID)
+	}
+	if id := end.Descriptors[0].SegmentationEventID; id != "0x00000065" {
+		t.Errorf("segmentation event id is %v", id)
+	}
+	if ok, reason := cuei.ValidPair(start, end); !ok {
+		t.Error(reason)
+	}
+}
+
+func TestRenumberEventsMerged(t *testing.T) {
+	insert := cuei.NewCue()
+	insert.Decode(legacyAvail)
+	seg := segmentation(0x34)
+	seg.SegmentationEventID = "0x4800008f"
+	signal := withDescriptors(seg)
+	ids := cuei.RenumberEvents([]*cuei.Cue{insert, signal}, 1)
+	if len(ids) != 1 || ids[0x4800008f] != 1 {
+		t.Fatalf("ids are %v", ids)
+	}
+	if id := signal.Descriptors[0].SegmentationEventID; insert.Command.SpliceEventID != 1 || id != "0x00000001" {
+		t.Errorf("splice event id is %v, segmentation event id is %v", insert.Command.SpliceEventID, id)
+	}
+}
+
+func TestSetFixedEnd(t *testing.T) {
+	cue := cuei.NewCue()
+	cue.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	if err := cue.SetFixedEnd(cue.Command.PTS + 30.0); err != nil {
+		t.Fatal(err)
+	}
+	cue = roundTrip(t, cue)
+	if cue.Command.BreakDuration != 30.0 || cue.Command.BreakAutoReturn {
+		t.Errorf("break duration %v auto return %v", cue.Command.BreakDuration, cue.Command.BreakAutoReturn)
+	}
+
+	start := withDescriptors(segmentation(0x34))
+	start.Command.PTS = 95440.0
+	if err := start.SetFixedEnd(10.0); err != nil {
+		t.Fatal(err)
+	}
+	// 10 seconds after the wrap at 95443.717689
+	if got := start.Descriptors[0].SegmentationDuration; got != 13.717688 {
+		t.Errorf("segmentation duration across the wrap is %v", got)
+	}
+
+	immediate := withCommand(&cuei.Command{CommandType: 0x6})
+	if err := immediate.SetFixedEnd(10.0); err == nil {
+		t.Error("SetFixedEnd on an immediate time signal did not fail")
+	}
+}
+
+func TestEncodings(t *testing.T) {
+	for _, cue := range []*cuei.Cue{withDescriptors(segmentation(0x34)), cuei.NewSpliceCancel(7)} {
+		bites, b64, hx := cue.Encodings()
+		if !bytes.Equal(bites, cue.Encode()) || b64 != cue.Encode2B64() || hx != cue.Encode2Hex() {
+			t.Errorf("Encodings are %x %v %v", bites, b64, hx)
+		}
+	}
+}
+
+func TestSnapToFrame(t *testing.T) {
+	cases := []struct {
+		fps   float64
+		pts   float64
+		delta float64
+		want  float64
+	}{
+		{29.97, 10.0, 0.01, 10.01},               // 3003 ticks a frame
+		{23.976, 41.7, 750 / 90000.0, 41.708333}, // 3753.75 ticks a frame
+		{25, 10.0, 0, 10.0},
+		{59.94, 10.0, -601 / 90000.0, 9.993322}, // 1501.5 ticks a frame
+	}
+	for _, c := range cases {
+		cue := withDescriptors()
+		cue.Command.PTS = c.pts
+		if delta := cue.SnapToFrame(c.fps); delta != c.delta {
+			t.Errorf("%v fps delta is %v, want %v", c.fps, delta, c.delta)
+		}
+		cue = roundTrip(t, cue)
+		if cue.Command.PTS != c.want {
+			t.Errorf("%v fps pts is %v, want %v", c.fps, cue.Command.PTS, c.want)
+		}
+		if delta := cue.SnapToFrame(c.fps); delta != 0 {
+			t.Errorf("%v fps snapped pts moved again by %v", c.fps, delta)
+		}
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	cue := withDescriptors(segmentation(0x34))
+	cue.InfoSection.EncryptedPacket = true
+	cue.InfoSection.PtsAdjustment = 2.0
+	cue.InfoSection.Tier = "0x123"
+	cue2 := cuei.NewCue()
+	cue2.Decode(cue.Encode())
+	if !cue2.Encrypted() || !cue2.Ciphertext || len(cue2.Warnings) == 0 {
+		t.Errorf("encrypted %v ciphertext %v warnings %v", cue2.Encrypted(), cue2.Ciphertext, cue2.Warnings)
+	}
+	if cue2.InfoSection.PtsAdjustment != 2.0 || cue2.InfoSection.Tier != "0x123" {
+		t.Errorf("clear fields are pts adjustment %v tier %v", cue2.InfoSection.PtsAdjustment, cue2.InfoSection.Tier)
+	}
+	if cue2.Command.CommandType != 0x6 || cue2.Command.Name != "" || cue2.Descriptors != nil || cue2.Crc32 != cue.Crc32 {
+		t.Errorf("ciphertext decoded as command %+v descriptors %v crc32 %#x", cue2.Command, cue2.Descriptors, cue2.Crc32)
+	}
+	if want := cue.Encode()[14 : len(cue.Encode())-4]; !bytes.Equal(cue2.Command.RawBytes, want) {
+		t.Errorf("ciphertext is %x, want %x", cue2.Command.RawBytes, want)
+	}
+	if bites, err := cue2.MarshalBinary(); err != nil || !bytes.Equal(bites, cue.Encode()) {
+		t.Errorf("MarshalBinary of a ciphertext cue is %x, %v", bites, err)
+	}
+	dec := cuei.NewDecoder()
+	dec.Strict = true
+	strict, err := dec.Decode(cue.Encode())
+	if err != nil || !strict.Ciphertext {
+		t.Errorf("strict decode of an encrypted packet is %v, ciphertext %v", err, strict != nil && strict.Ciphertext)
+	}
+	if withDescriptors().Encrypted() {
+		t.Error("clear cue is encrypted")
+	}
+}
+
+func TestPadTo(t *testing.T) {
+	cue := withDescriptors(segmentation(0x34))
+	if err := cue.PadTo(cue.EncodedLen() - 1); err == nil {
+		t.Error("PadTo smaller than the cue did not fail")
+	}
+	if err := cue.PadTo(64); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(cue.Encode()); n != 64 {
+		t.Fatalf("padded cue is %v bytes", n)
+	}
+	cue2 := roundTrip(t, cue)
+	if cue2.InfoSection.Stuffing != cue.InfoSection.Stuffing || cue2.Crc32 != cue.Crc32 {
+		t.Errorf("stuffing %v crc32 %#x, want %v %#x", cue2.InfoSection.Stuffing, cue2.Crc32, cue.InfoSection.Stuffing, cue.Crc32)
+	}
+	if len(cue2.Descriptors) != 1 || len(cue2.Warnings) != 0 {
+		t.Errorf("padded cue decoded %v descriptors with warnings %v", len(cue2.Descriptors), cue2.Warnings)
+	}
+	if err := cue2.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSplitSegmentations(t *testing.T) {
+	avail := cuei.Descriptor{Tag: 0x0, ProviderAvailID: 0x135}
+	cue := withDescriptors(segmentation(0x34), avail, segmentation(0x10))
+	cue.InfoSection.PtsAdjustment = 5.0
+	cue.Encode()
+	dll := cue.Dll
+	cues := cue.SplitSegmentations()
+	if len(cues) != 2 || len(cue.Descriptors) != 3 || cue.Dll != dll {
+		t.Fatalf("split into %v cues, the cue has %v descriptors", len(cues), len(cue.Descriptors))
+	}
+	for i, want := range []uint8{0x34, 0x10} {
+		got := roundTrip(t, cues[i])
+		if len(got.Descriptors) != 1 || got.Descriptors[0].SegmentationTypeID != want {
+			t.Errorf("cue %v descriptors are %+v", i, got.Descriptors)
+		}
+		if got.InfoSection.PtsAdjustment != 5.0 || got.Command.PTS != cue.Command.PTS {
+			t.Errorf("cue %v pts adjustment %v pts %v", i, got.InfoSection.PtsAdjustment, got.Command.PTS)
+		}
+		if err := got.Validate(); err != nil {
+			t.Errorf("cue %v: %v", i, err)
+		}
+	}
+}
+
+func TestMatchesPolicy(t *testing.T) {
+	allowed := []uint8{0x34, 0x35}
+	if !withDescriptors(segmentation(0x34), segmentation(0x35)).MatchesPolicy(allowed) {
+		t.Error("allowed types do not match")
+	}
+	if withDescriptors(segmentation(0x34), segmentation(0x10)).MatchesPolicy(allowed) {
+		t.Error("a type that is not allowed matches")
+	}
+	none := withDescriptors(cuei.Descriptor{Tag: 0x0, ProviderAvailID: 1})
+	if !none.MatchesPolicy(allowed) {
+		t.Error("no segmentation descriptors does not match by default")
+	}
+	cuei.PolicyDefault = false
+	defer func() { cuei.PolicyDefault = true }()
+	if none.MatchesPolicy(allowed) {
+		t.Error("no segmentation descriptors matches with PolicyDefault false")
+	}
+}
+
+func TestInTier(t *testing.T) {
+	cue := withDescriptors()
+	if !cue.InTier(0x1) || !cue.InTier(0xfff) {
+		t.Error("tier 0xfff does not match every device tier")
+	}
+	cue.InfoSection.Tier = "0x123"
+	cue = roundTrip(t, cue)
+	if !cue.InTier(0x123) {
+		t.Error("tier 0x123 does not match device tier 0x123")
+	}
+	if cue.InTier(0x124) || cue.InTier(0xfff) {
+		t.Error("tier 0x123 matches another device tier")
+	}
+}
+
+func TestPreserveCommandLength(t *testing.T) {
+	bites := withDescriptors().Encode()
+	// splice_command_length 0xfff, as some encoders write it
+	bites[11], bites[12] = bites[11]|0x0f, 0xff
+	cue, err := cuei.NewDecoder().Decode(bites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := cuei.EncodeOptions{PreserveCommandLength: true, KeepCrc32: true}
+	if got := cue.EncodeWith(opts); !bytes.Equal(got, bites) {
+		t.Errorf("preserved encode is %x, want %x", got, bites)
+	}
+	if cue.Encode(); cue.InfoSection.CommandLength != 5 {
+		t.Errorf("Encode command length is %#x, want 5", cue.InfoSection.CommandLength)
+	}
+}
+
+func TestEncodeDeterministic(t *testing.T) {
+	restricted := segmentation(0x30)
+	restricted.DeliveryNotRestrictedFlag = false
+	restricted.DeviceRestrictions = "Restrict Group 2"
+	avail := cuei.Descriptor{Tag: 0x0, ProviderAvailID: 0x135}
+	cue := withDescriptors(midSegmentation(3, 20), restricted, avail)
+	want := cue.Encode()
+	for i := 0; i < 100; i++ {
+		if got := cue.Encode(); !bytes.Equal(got, want) {
+			t.Fatalf("encode %v is %x, want %x", i, got, want)
+		}
+	}
+}
+
+func TestOffsetFrom(t *testing.T) {
+	cue := withDescriptors(segmentation(0x34))
+	cue.Command.PTS = 10.0
+	cue.InfoSection.PtsAdjustment = 2.0
+	tests := []struct {
+		base, want float64
+	}{
+		{8.0, 4.0},
+		{12.0, 0.0},
+		{14.5, -2.5},
+		// the segment starts before the 33 bit wrap
+		{95443.0, 12.717689},
+	}
+	for _, test := range tests {
+		got, ok := cue.OffsetFrom(test.base)
+		if !ok || fmt.Sprintf("%.6f", got) != fmt.Sprintf("%.6f", test.want) {
+			t.Errorf("OffsetFrom(%v) is %v %v, want %v", test.base, got, ok, test.want)
+		}
+	}
+	cue.Command.TimeSpecifiedFlag = false
+	if _, ok := cue.OffsetFrom(8.0); ok {
+		t.Error("immediate time signal has an offset")
+	}
+}
+
+func TestEncodeNoDescriptors(t *testing.T) {
+	// the splice insert of testData with its avail descriptor removed
+	want := "/DAlAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAAYinJUA=="
+	for _, dscptrs := range [][]cuei.Descriptor{nil, {}} {
+		cue := cuei.NewCue()
+		cue.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+		cue.Descriptors = dscptrs
+		if got := cue.Encode2B64(); got != want {
+			t.Errorf("encode is %v, want %v", got, want)
+		}
+		if cue.Dll != 0 || cue.InfoSection.SectionLength != 37 {
+			t.Errorf("dll is %v, section length %v", cue.Dll, cue.InfoSection.SectionLength)
+		}
+		if err := roundTrip(t, cue).Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestIsInsertable(t *testing.T) {
+	insert := cuei.NewCue()
+	insert.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	if !insert.IsInsertable() {
+		t.Error("out of network splice insert with a duration is not insertable")
+	}
+	insert.Command.DurationFlag = false
+	if insert.IsInsertable() {
+		t.Error("splice insert without a duration is insertable")
+	}
+	tests := []struct {
+		typeID uint8
+		want   bool
+	}{
+		{0x34, true},
+		{0x36, true},
+		{0x35, false},
+		{0x30, false},
+		{0x01, false},
+	}
+	for _, test := range tests {
+		if got := withDescriptors(segmentation(test.typeID)).IsInsertable(); got != test.want {
+			t.Errorf("type %#x insertable is %v, want %v", test.typeID, got, test.want)
+		}
+	}
+	cuei.InsertableTypes[0x30] = true
+	defer delete(cuei.InsertableTypes, 0x30)
+	if !withDescriptors(segmentation(0x30)).IsInsertable() {
+		t.Error("type 0x30 added to InsertableTypes is not insertable")
+	}
+}
+
+func TestEffectivePTS(t *testing.T) {
+	cue := withDescriptors(segmentation(0x34))
+	cue.Command.PTS = 95000.0
+	cue.InfoSection.PtsAdjustment = 1000.0
+	got := roundTrip(t, cue)
+	if got.Command.PTS != 95000.0 || got.InfoSection.PtsAdjustment != 1000.0 {
+		t.Errorf("round trip pts %v pts adjustment %v, want 95000 and 1000", got.Command.PTS, got.InfoSection.PtsAdjustment)
+	}
+	if got.Command.PTSRaw != 95000*90000 || got.InfoSection.PtsAdjustmentRaw != 1000*90000 {
+		t.Errorf("round trip raw pts %v pts adjustment %v", got.Command.PTSRaw, got.InfoSection.PtsAdjustmentRaw)
+	}
+	// 96000 wraps at 95443.717688
+	if pts, ok := got.EffectivePTS(); !ok || pts != 556.282311 {
+		t.Errorf("EffectivePTS is %v %v, want 556.282311", pts, ok)
+	}
+	if got.Command.PTS != 95000.0 || got.InfoSection.PtsAdjustment != 1000.0 {
+		t.Error("EffectivePTS changed the cue")
+	}
+	got.Command.TimeSpecifiedFlag = false
+	if _, ok := got.EffectivePTS(); ok {
+		t.Error("immediate time signal has an effective pts")
+	}
+}
+
+func TestLegacyAvail(t *testing.T) {
+	out, err := cuei.NewDecoder().Decode(legacyAvail)
+	if err != nil || len(out.Warnings) != 0 {
+		t.Fatalf("decode: %v %v", err, out.Warnings)
+	}
+	if len(out.Descriptors) != 1 || out.Descriptors[0].Name != "Avail Descriptor" || out.Descriptors[0].ProviderAvailID != 0x135 {
+		t.Fatalf("descriptors are %+v", out.Descriptors)
+	}
+	if !out.IsOut() {
+		t.Error("out of network splice insert is not out")
+	}
+	in, err := out.MakeReturn(out.Command.PTS + 60.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.IsOut() || len(in.Descriptors) != 1 {
+		t.Errorf("return is out %v with %v descriptors", in.IsOut(), len(in.Descriptors))
+	}
+	// a segmentation end decides over the command flag
+	out.Descriptors = append(out.Descriptors, segmentation(0x35))
+	if out.IsOut() {
+		t.Error("splice insert with a segmentation end is out")
+	}
+}
+
+func TestRefreshCRC(t *testing.T) {
+	cue := cuei.NewCue()
+	cue.Decode(legacyAvail)
+	cue.InfoSection.PtsAdjustment = 10.5
+	if err := cue.RefreshCRC(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cue.Validate(); err != nil {
+		t.Error(err)
+	}
+	crc := cue.Crc32
+	if cue.Encode(); cue.Crc32 != crc {
+		t.Errorf("RefreshCRC crc32 is %#x, Encode is %#x", crc, cue.Crc32)
+	}
+	cue.Descriptors = append(cue.Descriptors, segmentation(0x34))
+	if err := cue.RefreshCRC(); err == nil || cue.Crc32 != crc {
+		t.Errorf("RefreshCRC with a new descriptor is %v, crc32 %#x", err, cue.Crc32)
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	avail := func(pts, duration float64) *cuei.Cue {
+		dscptr := segmentation(0x34)
+		dscptr.SegmentationDurationFlag = true
+		dscptr.SegmentationDuration = duration
+		cue := withDescriptors(dscptr)
+		cue.Command.PTS = pts
+		cue.Encode()
+		return cue
+	}
+	tests := []struct {
+		name string
+		a, b *cuei.Cue
+		want bool
+	}{
+		{"nested", avail(100.0, 60.0), avail(120.0, 10.0), true},
+		{"b first", avail(120.0, 30.0), avail(100.0, 30.0), true},
+		{"back to back", avail(100.0, 30.0), avail(130.0, 30.0), false},
+		{"apart", avail(100.0, 30.0), avail(200.0, 30.0), false},
+		// a runs across the 33 bit wrap at 95443.717688
+		{"across the wrap", avail(95430.0, 30.0), avail(5.0, 10.0), true},
+		{"after the wrap", avail(95430.0, 30.0), avail(20.0, 10.0), false},
+	}
+	for _, test := range tests {
+		got, err := cuei.Overlaps(test.a, test.b)
+		if err != nil || got != test.want {
+			t.Errorf("%v overlaps is %v %v, want %v", test.name, got, err, test.want)
+		}
+	}
+	if _, err := cuei.Overlaps(avail(100.0, 30.0), withDescriptors(segmentation(0x34))); err == nil {
+		t.Error("overlap with a cue without a duration did not fail")
+	}
+}
+
+func TestMakeReturnSpliceInsert(t *testing.T) {
+	out := withCommand(&cuei.Command{
+		CommandType:           0x5,
+		SpliceEventID:         7,
+		OutOfNetworkIndicator: true,
+		Components: []cuei.SpliceComponent{
+			{ComponentTag: 1, TimeSpecifiedFlag: true, PTS: 10.0},
+			{ComponentTag: 2, TimeSpecifiedFlag: true, PTS: 10.5},
+		},
+	})
+	out.Descriptors = []cuei.Descriptor{segmentation(0x34), {Tag: 0x0, ProviderAvailID: 9}}
+	out.Encode()
+	in, err := out.MakeReturn(70.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in = roundTrip(t, in)
+	if len(in.Command.Components) != 2 {
+		t.Fatalf("return has %v components", len(in.Command.Components))
+	}
+	for i, comp := range in.Command.Components {
+		if comp.PTS != 70.0 || !comp.TimeSpecifiedFlag {
+			t.Errorf("return component %v pts is %v", i, comp.PTS)
+		}
+	}
+	if out.Command.Components[0].PTS != 10.0 || out.Descriptors[0].SegmentationTypeID != 0x34 {
+		t.Error("MakeReturn changed the out cue")
+	}
+	if in.IsOut() || len(in.Descriptors) != 2 || in.Descriptors[0].SegmentationTypeID != 0x35 {
+		t.Errorf("return is out %v with descriptors %+v", in.IsOut(), in.Descriptors)
+	}
+	if ok, reason := cuei.ValidPair(out, in); !ok {
+		t.Errorf("component pair: %v", reason)
+	}
+	cancel := cuei.NewSpliceCancel(7)
+	cancel.Command.OutOfNetworkIndicator = true
+	if _, err := cancel.MakeReturn(70.0); err == nil {
+		t.Error("return of a cancelled splice insert did not fail")
+	}
+}
+
+func TestEncodedLenNoSideEffects(t *testing.T) {
+	if n := (&cuei.Cue{}).EncodedLen(); n != 0 {
+		t.Errorf("EncodedLen of an empty Cue is %v, want 0", n)
+	}
+	cue := withDescriptors(segmentation(0x34))
+	cue.Command.PTS = 20
+	cue.Command.PTSRaw = 7
+	cue.EncodedLen()
+	if cue.Command.PTSRaw != 7 {
+		t.Errorf("EncodedLen set PTSRaw to %v", cue.Command.PTSRaw)
+	}
+	cmds := []*cuei.Command{
+		{CommandType: 0x5, SpliceEventID: 1, SpliceEventCancelIndicator: true},
+		{CommandType: 0x5, SpliceEventID: 1, ProgramSpliceFlag: true, SpliceImmediateFlag: true},
+		{CommandType: 0x5, SpliceEventID: 1, DurationFlag: true, BreakDuration: 30, Components: []cuei.SpliceComponent{
+			{ComponentTag: 1, TimeSpecifiedFlag: true, PTS: 10}, {ComponentTag: 2},
+		}},
+		{CommandType: 0x4, SpliceEvents: []cuei.SpliceEvent{
+			{SpliceEventID: 1, SpliceEventCancelIndicator: true},
+			{SpliceEventID: 2, DurationFlag: true, BreakDuration: 30, Components: []cuei.ScheduleComponent{{ComponentTag: 1}}},
+		}},
+	}
+	for _, cmd := range cmds {
+		cue := withCommand(cmd)
+		if got, want := cue.EncodedLen(), len(cue.Encode()); got != want {
+			t.Errorf("command %+v: EncodedLen is %v, Encode returned %v bytes", cmd, got, want)
+		}
+	}
+}
+
+func TestEncryptedReEncode(t *testing.T) {
+	encrypted := func(typeID uint8) []byte {
+		cue := withDescriptors(segmentation(typeID))
+		cue.InfoSection.EncryptedPacket = true
+		return cue.Encode()
+	}
+	bites := encrypted(0x34)
+	cue := cuei.NewCue()
+	cue.Decode(bites)
+	if got := cue.Encode(); !bytes.Equal(got, bites) || cue.EncodedLen() != len(bites) {
+		t.Errorf("ciphertext re-encodes to %x, EncodedLen %v, want %x", got, cue.EncodedLen(), bites)
+	}
+	if got := cue.ReEncodeB64(); got != cue.Encode2B64() {
+		t.Errorf("ReEncodeB64 is %v", got)
+	}
+	other := cuei.NewCue()
+	other.Decode(encrypted(0x36))
+	if cue.Hash64() == other.Hash64() || cue.Fingerprint(false) == other.Fingerprint(false) {
+		t.Error("different ciphertexts hash the same")
+	}
+	ciphertext := cue.Command.RawBytes
+	cue.AdjustPts(2.0)
+	back := cuei.NewCue()
+	if !back.Decode(cue.Encode()) || back.InfoSection.PtsAdjustment != 2.0 || !bytes.Equal(back.Command.RawBytes, ciphertext) {
+		t.Errorf("adjusted ciphertext decoded as %+v %x", back.InfoSection, back.Command.RawBytes)
+	}
+	if err := cue.RefreshCRC(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestShiftTimeEmpty(t *testing.T) {
+	cue := cuei.NewCue()
+	cue.ShiftTime(10.0)
+	if cue.Command != nil || cue.InfoSection != nil {
+		t.Errorf("ShiftTime changed an empty cue to %+v", cue)
+	}
+}
@@ -0,0 +1,76 @@
+package cuei
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteCues encodes each of cues to base64 and writes one per line to w.
+func WriteCues(w io.Writer, cues []*Cue) error {
+	bw := bufio.NewWriter(w)
+	for _, cue := range cues {
+		_, err := fmt.Fprintln(bw, cue.Encode2B64())
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+/*
+ReadCues reads base64 encoded Cues from r, one per line.
+
+	Blank lines and lines starting with # are skipped.
+*/
+func ReadCues(r io.Reader) ([]*Cue, error) {
+	var cues []*Cue
+	dec := NewDecoder()
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		cue, err := dec.Decode(text)
+		if err != nil {
+			return cues, fmt.Errorf("line %v: %v", line, err)
+		}
+		cues = append(cues, cue)
+	}
+	return cues, scanner.Err()
+}
+
+/*
+LoadCuesJSON reads a JSON array of Cues in the shape Show prints from r,
+and encodes each Cue.
+
+	An error names the array index of the Cue that failed,
+	the Cues before it are returned.
+*/
+func LoadCuesJSON(r io.Reader) ([]*Cue, error) {
+	var raws []json.RawMessage
+	err := json.NewDecoder(r).Decode(&raws)
+	if err != nil {
+		return nil, err
+	}
+	var cues []*Cue
+	for i, raw := range raws {
+		cue := NewCue()
+		err = json.Unmarshal(raw, cue)
+		if err == nil && (cue.InfoSection == nil || cue.Command == nil) {
+			err = errors.New("no InfoSection or Command")
+		}
+		if err != nil {
+			return cues, fmt.Errorf("cue %v: %v", i, err)
+		}
+		cue.Encode()
+		cues = append(cues, cue)
+	}
+	return cues, nil
+}
@@ -0,0 +1,48 @@
+package cuei_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestReadCuesError(t *testing.T) {
+	corpus := timeSignal + "\n/DAWAAAA\n"
+	cues, err := cuei.ReadCues(strings.NewReader(corpus))
+	if err == nil || err.Error() != "line 2: cue is truncated" {
+		t.Errorf("error is %v", err)
+	}
+	if len(cues) != 1 {
+		t.Errorf("read %v cues before the error, want 1", len(cues))
+	}
+}
+
+func TestLoadCuesJSON(t *testing.T) {
+	insert := cuei.NewCue()
+	insert.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	want := []*cuei.Cue{insert, withDescriptors(segmentation(0x34))}
+	js, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cues, err := cuei.LoadCuesJSON(bytes.NewReader(js))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cues) != len(want) {
+		t.Fatalf("loaded %v cues, want %v", len(cues), len(want))
+	}
+	for i, cue := range cues {
+		if cue.Encode2B64() != want[i].Encode2B64() {
+			t.Errorf("cue %v is %v, want %v", i, cue.Encode2B64(), want[i].Encode2B64())
+		}
+	}
+	bad := `[` + string(js[1:len(js)-1]) + `, {"Command": 5}]`
+	cues, err = cuei.LoadCuesJSON(strings.NewReader(bad))
+	if err == nil || !strings.HasPrefix(err.Error(), "cue 2:") || len(cues) != 2 {
+		t.Errorf("loaded %v cues with error %v", len(cues), err)
+	}
+}
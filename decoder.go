@@ -0,0 +1,163 @@
+package cuei
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrBadTableID is returned by a Strict Decoder for a table id other than 0xfc.
+var ErrBadTableID = errors.New("table id is not 0xfc")
+
+/*
+ErrSectionLength is returned by a Strict Decoder when the command,
+descriptor loop and crc32 do not fill section_length,
+other than with 0xff alignment stuffing.
+*/
+var ErrSectionLength = errors.New("section length does not match the section")
+
+/*
+Decoder decodes SCTE-35 Cues with options.
+
+	The On callbacks are called as each part of a Cue is decoded,
+	a nil callback is skipped.
+
+	The descriptor loop is bounded by descriptor_loop_length
+	and by section_length, the tighter bound is used.
+	A descriptor_loop_length past the crc32 that section_length places
+	is cut to it with a warning, a section_length past the end
+	of the bytes is ignored, and a shorter descriptor_loop_length
+	leaves the bytes before the crc32 as alignment stuffing.
+*/
+type Decoder struct {
+	Strict       bool                 // Return an error instead of recording a warning, and reject trailing bytes and extra section bytes.
+	OnCommand    func(*Command)       // Called after the Splice Command is decoded.
+	OnDescriptor func(*Descriptor)    // Called after each Splice Descriptor is decoded.
+	OnWarning    func(warning string) // Called with each warning.
+	HeaderOnly   bool                 // Skip the descriptor loop, the Cue is marked Partial.
+	Recover      bool                 // Decode descriptors found before the crc32 when the descriptor loop length is 0.
+	LazyUPIDs    bool                 // Keep Segmentation Upids as UpidBytes, Descriptor.ParseUPID decodes them.
+}
+
+// Decode takes Cue data as []byte, base64 or hex string and returns a *Cue.
+func (dec *Decoder) Decode(i interface{}) (*Cue, error) {
+	cue := NewCue()
+	err := cue.decode(dec, i)
+	return cue, err
+}
+
+/*
+DecodeSection decodes a splice info section
+that may still have the MPEGTS pointer_field in front of it.
+
+	When b does not start with the 0xfc table id,
+	b[0] is taken as the pointer_field and that many bytes are skipped.
+*/
+func DecodeSection(b []byte) (*Cue, error) {
+	if len(b) > 0 && b[0] != 0xfc {
+		skip := 1 + int(b[0])
+		if skip >= len(b) || b[skip] != 0xfc {
+			return nil, errors.New("no splice info section after the pointer field")
+		}
+		b = b[skip:]
+	}
+	return NewDecoder().Decode(b)
+}
+
+/*
+FromSample decodes b, a sample that is a splice_info_section
+with no other framing, as SCTE-35 is carried in MMT
+and in CMAF event message tracks.
+
+	b must start with the 0xfc table id and hold the whole section,
+	a pointer_field, PES header or emsg box is an error here,
+	use DecodeSection or FromEMSG for those.
+*/
+func FromSample(b []byte) (*Cue, error) {
+	// the smallest section is a splice null with no descriptors
+	if len(b) < 20 {
+		return nil, fmt.Errorf("sample is %v bytes, too short for a splice info section", len(b))
+	}
+	if b[0] != 0xfc {
+		return nil, fmt.Errorf("%w, the sample starts with %#x", ErrBadTableID, b[0])
+	}
+	if end := 3 + int(parseLen(b[1], b[2])); end > len(b) {
+		return nil, fmt.Errorf("section length %v is more than the %v bytes of the sample", end-3, len(b)-3)
+	}
+	return NewDecoder().Decode(b)
+}
+
+/*
+FindCues scans b for splice info sections and returns the Cues
+that decode cleanly, in the order they are found.
+
+	Each 0xfc byte is taken as a possible table id,
+	a section is only kept when the crc32 of its claimed length is valid,
+	so stray 0xfc bytes in a messy capture are skipped.
+	Scanning resumes after the end of each Cue found.
+*/
+func FindCues(b []byte) []*Cue {
+	var cues []*Cue
+	for i := 0; i+3 <= len(b); i++ {
+		if b[i] != 0xfc {
+			continue
+		}
+		end := i + 3 + (int(b[i+1]&0xf)<<8 | int(b[i+2]))
+		// the smallest section is a splice null with no descriptors
+		if end > len(b) || end-i < 20 {
+			continue
+		}
+		section := b[i:end]
+		if cRC32(section[:len(section)-4]) != binary.BigEndian.Uint32(section[len(section)-4:]) {
+			continue
+		}
+		cue, err := NewDecoder().Decode(section)
+		if err != nil {
+			continue
+		}
+		cues = append(cues, cue)
+		i = end - 1
+	}
+	return cues
+}
+
+/*
+PeekHeader reads the table id, section length and command type
+from the fixed header of a splice info section without decoding it.
+
+	ok is false when b is shorter than the 14 byte header.
+*/
+func PeekHeader(b []byte) (tableID uint8, sectionLength uint16, commandType uint8, ok bool) {
+	if len(b) < 14 {
+		return 0, 0, 0, false
+	}
+	return b[0], uint16(b[1]&0xf)<<8 | uint16(b[2]), b[13], true
+}
+
+// DecodeHex decodes a hex string with an optional 0x prefix, any other character is an error.
+func DecodeHex(str string) (*Cue, error) {
+	bites, err := decHex(str)
+	if err != nil {
+		return nil, err
+	}
+	return NewDecoder().Decode(bites)
+}
+
+// warn appends a warning to cue.Warnings, in strict mode it is returned as an error.
+func (dec *Decoder) warn(cue *Cue, format string, a ...interface{}) error {
+	msg := fmt.Sprintf(format, a...)
+	if dec.OnWarning != nil {
+		dec.OnWarning(msg)
+	}
+	if dec.Strict {
+		return errors.New(msg)
+	}
+	cue.Warnings = append(cue.Warnings, msg)
+	return nil
+}
+
+// initialize and return a *Decoder
+func NewDecoder() *Decoder {
+	dec := &Decoder{}
+	return dec
+}
@@ -0,0 +1,252 @@
+package cuei_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestStrictTrailingBytes(t *testing.T) {
+	bites := withCommand(&cuei.Command{CommandType: 0x0}).Encode()
+	stuffed := append(append([]byte(nil), bites...), 0xff, 0xff)
+	doubled := append(append([]byte(nil), bites...), bites...)
+	strict := cuei.NewDecoder()
+	strict.Strict = true
+	if _, err := strict.Decode(stuffed); err != nil {
+		t.Errorf("strict decode with stuffing: %v", err)
+	}
+	if _, err := strict.Decode(doubled); err == nil {
+		t.Error("strict decode of two cues did not fail")
+	}
+	if _, err := cuei.NewDecoder().Decode(doubled); err != nil {
+		t.Errorf("lenient decode of two cues: %v", err)
+	}
+}
+
+func TestDecodeHexDump(t *testing.T) {
+	dumps := []string{
+		"0xfc301600000000000000fff00506fe00a98ac700000b3baed9",
+		"FC301600000000000000FFF00506FE00A98AC700000B3BAED9",
+		"FC 30 16 00 00 00 00 00 00 00 FF F0 05 06 FE 00 A9 8A C7 00 00 0B 3B AE D9",
+		"fc:30:16:00:00:00:00:00:00:00:ff:f0:05:06:fe:00:a9:8a:c7:00:00:0b:3b:ae:d9",
+	}
+	for _, dump := range dumps {
+		cue := cuei.NewCue()
+		if !cue.Decode(dump) || cue.Encode2B64() != timeSignal {
+			t.Errorf("%q decoded to %v", dump, cue.Encode2B64())
+		}
+	}
+	if _, err := cuei.DecodeHex(dumps[0]); err != nil {
+		t.Errorf("DecodeHex: %v", err)
+	}
+	if _, err := cuei.DecodeHex(dumps[2]); err == nil {
+		t.Error("DecodeHex took a hex dump with spaces")
+	}
+}
+
+func TestBadTableID(t *testing.T) {
+	bites := withDescriptors().Encode()
+	bites[0] = 0xfd
+	cue, err := cuei.NewDecoder().Decode(bites)
+	if err != nil || len(cue.Warnings) != 1 || cue.Command.CommandType != 0x6 {
+		t.Errorf("lenient decode: %v, warnings %v", err, cue.Warnings)
+	}
+	strict := cuei.NewDecoder()
+	strict.Strict = true
+	if _, err = strict.Decode(bites); !errors.Is(err, cuei.ErrBadTableID) {
+		t.Errorf("strict decode error is %v, want ErrBadTableID", err)
+	}
+}
+
+const benchCue = "/DCtAAAAAAAAAP/wBQb+Tq9DwQCXAixDVUVJCUvhcH+fAR1QQ1IxXzEyMTYyMTE0MDBXQUJDUkFDSEFFTFJBWSEBAQIsQ1VFSQlL4W9/nwEdUENSMV8xMjE2MjExNDAwV0FCQ1JBQ0hBRUxSQVkRAQECGUNVRUkJTBwVf58BClRLUlIxNjA4NEEQAQECHkNVRUkJTBwWf98AA3clYAEKVEtSUjE2MDg0QSABAdHBXYA="
+
+// BenchmarkCueDecode decodes with a new Decoder for each Cue.
+func BenchmarkCueDecode(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		cue := cuei.NewCue()
+		cue.Decode(benchCue)
+	}
+}
+
+// BenchmarkDecoderDecode reuses one Decoder.
+func BenchmarkDecoderDecode(b *testing.B) {
+	dec := cuei.NewDecoder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		dec.Decode(benchCue)
+	}
+}
+
+func TestHeaderOnly(t *testing.T) {
+	full := withDescriptors(segmentation(0x34))
+	dec := cuei.NewDecoder()
+	dec.HeaderOnly = true
+	cue, err := dec.Decode(full.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cue.Partial || cue.Descriptors != nil || cue.Command.PTS != full.Command.PTS || cue.Crc32 != full.Crc32 {
+		t.Errorf("header only decode is %+v", cue)
+	}
+	if _, err = cue.MarshalBinary(); err == nil {
+		t.Error("MarshalBinary encoded a Partial cue")
+	}
+}
+
+func TestCommandLengthRule(t *testing.T) {
+	for _, tc := range []struct {
+		cmd    *cuei.Command
+		length byte
+		level  cuei.Level
+	}{
+		{&cuei.Command{CommandType: 0x0}, 2, cuei.Fail},
+		{&cuei.Command{CommandType: 0x6}, 0, cuei.Warn},
+		{&cuei.Command{CommandType: 0x6}, 5, cuei.Pass},
+	} {
+		bites := withCommand(tc.cmd).Encode()
+		bites[12] = tc.length // the low byte of splice_command_length
+		cue, _ := cuei.NewDecoder().Decode(bites)
+		for _, result := range cue.ConformanceReport().Results {
+			if result.Rule == "command_length" && result.Level != tc.level {
+				t.Errorf("command type %#x length %v is %v, want %v", tc.cmd.CommandType, tc.length, result.Level, tc.level)
+			}
+		}
+		err := cue.Validate()
+		if failed := err != nil && strings.Contains(err.Error(), "command_length"); failed != (tc.level == cuei.Fail) {
+			t.Errorf("command type %#x length %v: Validate is %v", tc.cmd.CommandType, tc.length, err)
+		}
+	}
+}
+
+func TestFindCues(t *testing.T) {
+	insert := cuei.NewCue()
+	insert.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	signal := withDescriptors(segmentation(0x34))
+	bad := append([]byte(nil), signal.Encode()...)
+	bad[len(bad)-1] ^= 0xff
+	var blob []byte
+	blob = append(blob, []byte("log line 0xfc \xfc\x30\x10 junk ")...)
+	blob = append(blob, insert.Encode()...)
+	blob = append(blob, bad...)
+	blob = append(blob, '\n', 0xfc)
+	blob = append(blob, signal.Encode()...)
+	blob = append(blob, 0xfc, 0xff)
+	cues := cuei.FindCues(blob)
+	if len(cues) != 2 {
+		t.Fatalf("found %v cues, want 2", len(cues))
+	}
+	if cues[0].Command.CommandType != 0x5 || cues[1].Command.CommandType != 0x6 {
+		t.Errorf("found command types %#x and %#x", cues[0].Command.CommandType, cues[1].Command.CommandType)
+	}
+}
+
+func TestRecoverDll(t *testing.T) {
+	bites := withDescriptors(segmentation(0x34)).Encode()
+	// zero the descriptor loop length after the 5 byte time signal
+	bites[19], bites[20] = 0, 0
+	cue, err := cuei.NewDecoder().Decode(bites)
+	if err != nil || len(cue.Descriptors) != 0 {
+		t.Fatalf("default decode found %v descriptors, err %v", len(cue.Descriptors), err)
+	}
+	dec := cuei.NewDecoder()
+	dec.Recover = true
+	cue, err = dec.Decode(bites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cue.Descriptors) != 1 || cue.Descriptors[0].SegmentationTypeID != 0x34 || len(cue.Warnings) != 1 {
+		t.Errorf("recovered %v descriptors with warnings %v", len(cue.Descriptors), cue.Warnings)
+	}
+	if cue.Crc32 != withDescriptors(segmentation(0x34)).Crc32 {
+		t.Errorf("crc32 is %#x after recovering", cue.Crc32)
+	}
+}
+
+func TestStrictSectionLength(t *testing.T) {
+	strict := cuei.NewDecoder()
+	strict.Strict = true
+	inner := withCommand(&cuei.Command{CommandType: 0x0}).Encode()
+	// a second cue glued inside the section, before the crc32
+	bites := withDescriptors().Encode()
+	glued := append(append([]byte(nil), bites[:len(bites)-4]...), inner...)
+	glued = append(glued, bites[len(bites)-4:]...)
+	length := len(glued) - 3
+	glued[1], glued[2] = glued[1]&0xf0|byte(length>>8), byte(length)
+	_, err := strict.Decode(glued)
+	if !errors.Is(err, cuei.ErrSectionLength) || !strings.Contains(err.Error(), fmt.Sprintf("%v bytes more", len(inner))) {
+		t.Errorf("strict decode of a glued section: %v", err)
+	}
+	if _, err := cuei.NewDecoder().Decode(glued); err != nil {
+		t.Errorf("lenient decode of a glued section: %v", err)
+	}
+	short := append([]byte(nil), inner...)
+	short[2] -= 2
+	_, err = strict.Decode(short)
+	if !errors.Is(err, cuei.ErrSectionLength) || !strings.Contains(err.Error(), "2 bytes short") {
+		t.Errorf("strict decode of a short section: %v", err)
+	}
+	padded := withDescriptors()
+	if err := padded.PadTo(64); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := strict.Decode(padded.Encode()); err != nil {
+		t.Errorf("strict decode with alignment stuffing: %v", err)
+	}
+}
+
+func TestLazyUPIDs(t *testing.T) {
+	bites := withDescriptors(midSegmentation(2, 20)).Encode()
+	lazy := cuei.NewDecoder()
+	lazy.LazyUPIDs = true
+	cue, err := lazy.Decode(bites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dscptr := &cue.Descriptors[0]
+	if dscptr.SegmentationUpid != nil || len(dscptr.UpidBytes) != 44 {
+		t.Fatalf("lazy decode upid %+v bytes %v", dscptr.SegmentationUpid, len(dscptr.UpidBytes))
+	}
+	if got := cue.Encode(); !bytes.Equal(got, bites) {
+		t.Errorf("lazy encode is %x, want %x", got, bites)
+	}
+	upid, err := dscptr.ParseUPID()
+	eager, _ := cuei.NewDecoder().Decode(bites)
+	if err != nil || fmt.Sprint(*upid) != fmt.Sprint(*eager.Descriptors[0].SegmentationUpid) {
+		t.Errorf("ParseUPID is %+v %v, want %+v", upid, err, eager.Descriptors[0].SegmentationUpid)
+	}
+	if again, _ := dscptr.ParseUPID(); again != upid {
+		t.Error("ParseUPID is not cached")
+	}
+	// the first upid of the mid runs past the end of the mid
+	cue, _ = lazy.Decode(bites)
+	cue.Descriptors[0].UpidBytes[1] = 60
+	if _, err := cue.Descriptors[0].ParseUPID(); err == nil {
+		t.Error("ParseUPID of a mid past its bytes did not fail")
+	}
+}
+
+func TestFromSample(t *testing.T) {
+	bites := withDescriptors(segmentation(0x34)).Encode()
+	cue, err := cuei.FromSample(bites)
+	if err != nil || len(cue.Descriptors) != 1 {
+		t.Fatalf("FromSample: %v", err)
+	}
+	withPointer := append([]byte{0x00}, bites...)
+	if _, err := cuei.FromSample(withPointer); !errors.Is(err, cuei.ErrBadTableID) {
+		t.Errorf("sample with a pointer field: %v", err)
+	}
+	for name, sample := range map[string][]byte{
+		"short":     bites[:12],
+		"truncated": bites[:len(bites)-4],
+		"text":      []byte("this is not a splice info section"),
+	} {
+		if _, err := cuei.FromSample(sample); err == nil {
+			t.Errorf("%v sample did not fail", name)
+		}
+	}
+}
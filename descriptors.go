@@ -2,11 +2,13 @@ package cuei
 
 import (
 	"fmt"
-	"log"
 )
 
-// audioCmpt is a struct for audioDscptr Components
-type audioCmpt struct {
+// cueIdentifier is "CUEI", the identifier of SCTE-35 splice descriptors.
+const cueIdentifier = 0x43554549
+
+// AudioComponent is an Audio Descriptor component
+type AudioComponent struct {
 	ComponentTag  uint8
 	ISOCode       uint32
 	BitstreamMode uint8
@@ -14,45 +16,56 @@ type audioCmpt struct {
 	FullSrvcAudio bool
 }
 
-// segCmpt Segmentation Descriptor Component
-type segCmpt struct {
+/*
+SegComponent is a Segmentation Descriptor Component,
+present when ProgramSegmentationFlag is not set.
+*/
+type SegComponent struct {
 	ComponentTag uint8
 	PtsOffset    float64
 }
 
 type Descriptor struct {
-	Tag                              uint8       `json:",omitempty"`
-	Length                           uint8       `json:",omitempty"`
-	Identifier                       string      `json:",omitempty"`
-	Name                             string      `json:",omitempty"`
-	AudioComponents                  []audioCmpt `json:",omitempty"`
-	ProviderAvailID                  uint32      `json:",omitempty"`
-	PreRoll                          uint8       `json:",omitempty"`
-	DTMFCount                        uint8       `json:",omitempty"`
-	DTMFChars                        uint64      `json:",omitempty"`
-	TAISeconds                       uint64      `json:",omitempty"`
-	TAINano                          uint32      `json:",omitempty"`
-	UTCOffset                        uint16      `json:",omitempty"`
-	SegmentationEventID              string      `json:",omitempty"`
-	SegmentationEventCancelIndicator bool        `json:",omitempty"`
-	ProgramSegmentationFlag          bool        `json:",omitempty"`
-	SegmentationDurationFlag         bool        `json:",omitempty"`
-	DeliveryNotRestrictedFlag        bool        `json:",omitempty"`
-	WebDeliveryAllowedFlag           bool        `json:",omitempty"`
-	NoRegionalBlackoutFlag           bool        `json:",omitempty"`
-	ArchiveAllowedFlag               bool        `json:",omitempty"`
-	DeviceRestrictions               string      `json:",omitempty"`
-	Components                       []segCmpt   `json:",omitempty"`
-	SegmentationDuration             float64     `json:",omitempty"`
-	SegmentationMessage              string      `json:",omitempty"`
-	SegmentationUpidType             uint8       `json:",omitempty"`
-	SegmentationUpidLength           uint8       `json:",omitempty"`
-	SegmentationUpid                 *Upid       `json:",omitempty"`
-	SegmentationTypeID               uint8       `json:",omitempty"`
-	SegmentNum                       uint8       `json:",omitempty"`
-	SegmentsExpected                 uint8       `json:",omitempty"`
-	SubSegmentNum                    uint8       `json:",omitempty"`
-	SubSegmentsExpected              uint8       `json:",omitempty"`
+	Tag                              uint8            `json:",omitempty"`
+	Length                           uint8            `json:",omitempty"`
+	Identifier                       uint32           `json:",omitempty"`
+	Name                             string           `json:",omitempty"`
+	AudioComponents                  []AudioComponent `json:",omitempty"`
+	ProviderAvailID                  uint32           `json:",omitempty"`
+	PreRoll                          uint8            `json:",omitempty"`
+	DTMFCount                        uint8            `json:",omitempty"`
+	DTMFChars                        uint64           `json:",omitempty"`
+	TAISeconds                       uint64           `json:",omitempty"`
+	TAINano                          uint32           `json:",omitempty"`
+	UTCOffset                        uint16           `json:",omitempty"`
+	SegmentationEventID              string           `json:",omitempty"`
+	SegmentationEventCancelIndicator bool             `json:",omitempty"`
+	EventIDComplianceIndicator       bool             `json:",omitempty"`
+	ProgramSegmentationFlag          bool             `json:",omitempty"`
+	SegmentationDurationFlag         bool             `json:",omitempty"`
+	DeliveryNotRestrictedFlag        bool             `json:",omitempty"`
+	WebDeliveryAllowedFlag           bool             `json:",omitempty"`
+	NoRegionalBlackoutFlag           bool             `json:",omitempty"` // the wire bit, encoded as is when RegionalBlackout is nil
+	RegionalBlackout                 *bool            `json:",omitempty"` // a regional blackout is in effect, the inverse of NoRegionalBlackoutFlag, when set it is encoded instead
+	ArchiveAllowedFlag               bool             `json:",omitempty"`
+	DeviceRestrictions               string           `json:",omitempty"`
+	SegComponents                    []SegComponent   `json:",omitempty"`
+	SegmentationDuration             float64          `json:",omitempty"`
+	SegmentationMessage              string           `json:",omitempty"`
+	SegmentationUpidType             uint8            `json:",omitempty"`
+	SegmentationUpidLength           uint8            `json:",omitempty"`
+	SegmentationUpid                 *Upid            `json:",omitempty"`
+	UpidBytes                        []byte           `json:",omitempty"` // the upid bytes kept by a Decoder with LazyUPIDs, until ParseUPID
+	SegmentationTypeID               uint8            `json:",omitempty"`
+	SegmentNum                       uint8            `json:",omitempty"`
+	SegmentsExpected                 uint8            `json:",omitempty"`
+	SubSegmentNum                    uint8            `json:",omitempty"`
+	SubSegmentsExpected              uint8            `json:",omitempty"`
+	SubSegmentsOmitted               bool             `json:",omitempty"` // decoded without sub_segment_num and sub_segments_expected, Encode leaves them out too
+	RawBytes                         []byte           `json:",omitempty"` // bytes after the identifier of an unknown tag
+	Trailing                         []byte           `json:",omitempty"` // bytes after the decoded fields of a known tag
+	lazyUpid                         bool             // keep the upid bytes in UpidBytes instead of decoding them
+	upidErr                          error            // the error of the first ParseUPID
 }
 
 // Return Descriptor as JSON
@@ -77,9 +90,18 @@ Decode returns a Splice Descriptor by tag.
 	    0x3: Time Descriptor,
 	    0x4: Audio Descrioptor,
 
+	Tags added with RegisterDescriptor and identifiers added with
+	RegisterPrivate use their DescriptorCodec,
+	any other tag keeps its bytes in RawBytes.
+
 *
 */
-func (dscptr *Descriptor) Decode(bd *bitDecoder, tag uint8, length uint8) {
+func (dscptr *Descriptor) Decode(bd *bitDecoder, tag uint8, length uint8) error {
+	dscptr.Identifier = bd.uInt32(32)
+	codec, ok := codecFor(tag, dscptr.Identifier)
+	if ok {
+		return dscptr.decodeCodec(codec, bd, tag, length)
+	}
 	switch tag {
 	case 0x0:
 		dscptr.Tag = 0x0
@@ -96,13 +118,26 @@ func (dscptr *Descriptor) Decode(bd *bitDecoder, tag uint8, length uint8) {
 	case 0x4:
 		dscptr.Tag = 0x4
 		dscptr.audioDescriptor(bd, tag, length)
+	default:
+		dscptr.Tag = tag
+		dscptr.Length = length
+		dscptr.RawBytes = bd.asBytes(dscptr.bodyBits())
 	}
+	return nil
+}
+
+// bodyBits is the number of bits after the identifier.
+func (dscptr *Descriptor) bodyBits() uint {
+	if dscptr.Length < 4 {
+		return 0
+	}
+	return uint(dscptr.Length-4) << 3
 }
 
 func (dscptr *Descriptor) audioDescriptor(bd *bitDecoder, tag uint8, length uint8) {
 	dscptr.Tag = tag
 	dscptr.Length = length
-	dscptr.Identifier = bd.asAscii(32)
+	dscptr.Name = "Audio Descriptor"
 	ccount := bd.uInt8(4)
 	bd.goForward(4)
 	for ccount > 0 {
@@ -112,7 +147,7 @@ func (dscptr *Descriptor) audioDescriptor(bd *bitDecoder, tag uint8, length uint
 		bsm := bd.uInt8(3)
 		nc := bd.uInt8(4)
 		fsa := bd.asFlag()
-		dscptr.AudioComponents = append(dscptr.AudioComponents, audioCmpt{ct, iso, bsm, nc, fsa})
+		dscptr.AudioComponents = append(dscptr.AudioComponents, AudioComponent{ct, iso, bsm, nc, fsa})
 	}
 }
 
@@ -120,7 +155,6 @@ func (dscptr *Descriptor) audioDescriptor(bd *bitDecoder, tag uint8, length uint
 func (dscptr *Descriptor) availDescriptor(bd *bitDecoder, tag uint8, length uint8) {
 	dscptr.Tag = tag
 	dscptr.Length = length
-	dscptr.Identifier = bd.asAscii(32)
 	dscptr.Name = "Avail Descriptor"
 	dscptr.ProviderAvailID = bd.uInt32(32)
 }
@@ -129,11 +163,10 @@ func (dscptr *Descriptor) availDescriptor(bd *bitDecoder, tag uint8, length uint
 func (dscptr *Descriptor) dtmfDescriptor(bd *bitDecoder, tag uint8, length uint8) {
 	dscptr.Tag = tag
 	dscptr.Length = length
-	dscptr.Identifier = bd.asAscii(32)
 	dscptr.Name = "DTMF Descriptor"
 	dscptr.PreRoll = bd.uInt8(8)
 	dscptr.DTMFCount = bd.uInt8(3)
-	//bd.goForward(5)
+	bd.goForward(5)
 	dscptr.DTMFChars = bd.uInt64(uint(8 * dscptr.DTMFCount))
 
 }
@@ -142,7 +175,6 @@ func (dscptr *Descriptor) dtmfDescriptor(bd *bitDecoder, tag uint8, length uint8
 func (dscptr *Descriptor) timeDescriptor(bd *bitDecoder, tag uint8, length uint8) {
 	dscptr.Tag = tag
 	dscptr.Length = length
-	dscptr.Identifier = bd.asAscii(32)
 	dscptr.Name = "Time Descriptor"
 	dscptr.TAISeconds = bd.uInt64(48)
 	dscptr.TAINano = bd.uInt32(32)
@@ -153,23 +185,34 @@ func (dscptr *Descriptor) timeDescriptor(bd *bitDecoder, tag uint8, length uint8
 func (dscptr *Descriptor) segmentationDescriptor(bd *bitDecoder, tag uint8, length uint8) {
 	dscptr.Tag = tag
 	dscptr.Length = length
-	dscptr.Identifier = bd.asAscii(32)
-	if dscptr.Identifier != "CUEI" {
-		log.Fatal("Segmentation Descriptor Identifies is not 0x43554549 but is ", dscptr.Identifier)
-	}
 	dscptr.Name = "Segmentation Descriptor"
+	// the identifier has been read, end is the bit after the descriptor.
+	end := bd.idx + uint(length)<<3 - 32
 	dscptr.SegmentationEventID = bd.asHex(32)
 	dscptr.SegmentationEventCancelIndicator = bd.asFlag()
-	bd.goForward(7)
+	dscptr.EventIDComplianceIndicator = bd.asFlag()
+	bd.goForward(6)
 	if !dscptr.SegmentationEventCancelIndicator {
 		dscptr.decodeSegFlags(bd)
 		if !dscptr.ProgramSegmentationFlag {
 			dscptr.decodeSegCmpnts(bd)
 		}
-		dscptr.decodeSegmentation(bd)
+		dscptr.decodeSegmentation(bd, end)
 	}
 }
 
+/*
+HasDuration returns true if a Segmentation Descriptor
+carries a SegmentationDuration.
+
+	The 40 bit segmentation_duration is only present
+	when SegmentationDurationFlag is set,
+	otherwise SegmentationDuration is left at zero.
+*/
+func (dscptr *Descriptor) HasDuration() bool {
+	return dscptr.Tag == 0x2 && dscptr.SegmentationDurationFlag
+}
+
 func (dscptr *Descriptor) decodeSegFlags(bd *bitDecoder) {
 	dscptr.ProgramSegmentationFlag = bd.asFlag()
 	dscptr.SegmentationDurationFlag = bd.asFlag()
@@ -177,6 +220,8 @@ func (dscptr *Descriptor) decodeSegFlags(bd *bitDecoder) {
 	if !dscptr.DeliveryNotRestrictedFlag {
 		dscptr.WebDeliveryAllowedFlag = bd.asFlag()
 		dscptr.NoRegionalBlackoutFlag = bd.asFlag()
+		blackout := !dscptr.NoRegionalBlackoutFlag
+		dscptr.RegionalBlackout = &blackout
 		dscptr.ArchiveAllowedFlag = bd.asFlag()
 		dscptr.DeviceRestrictions = table20[bd.uInt8(2)] // 8
 	} else {
@@ -191,17 +236,20 @@ func (dscptr *Descriptor) decodeSegCmpnts(bd *bitDecoder) {
 		ct := bd.uInt8(8)
 		bd.goForward(7)
 		po := bd.as90k(33)
-		dscptr.Components = append(dscptr.Components, segCmpt{ct, po})
+		dscptr.SegComponents = append(dscptr.SegComponents, SegComponent{ct, po})
 	}
 }
 
-func (dscptr *Descriptor) decodeSegmentation(bd *bitDecoder) {
+func (dscptr *Descriptor) decodeSegmentation(bd *bitDecoder, end uint) {
 	if dscptr.SegmentationDurationFlag {
 		dscptr.SegmentationDuration = bd.as90k(40)
 	}
+	// SegmentationUpidType is kept even when there are no upid bytes.
 	dscptr.SegmentationUpidType = bd.uInt8(8)
 	dscptr.SegmentationUpidLength = bd.uInt8(8)
-	if dscptr.SegmentationUpidLength > 0 {
+	if dscptr.SegmentationUpidLength > 0 && dscptr.lazyUpid {
+		dscptr.UpidBytes = bd.asBytes(uint(dscptr.SegmentationUpidLength) << 3)
+	} else if dscptr.SegmentationUpidLength > 0 {
 		dscptr.SegmentationUpid = &Upid{}
 		dscptr.SegmentationUpid.Decode(bd, dscptr.SegmentationUpidType, dscptr.SegmentationUpidLength)
 	}
@@ -214,21 +262,56 @@ func (dscptr *Descriptor) decodeSegmentation(bd *bitDecoder) {
 	dscptr.SegmentNum = bd.uInt8(8)
 	dscptr.SegmentsExpected = bd.uInt8(8)
 	subSegIDs := []uint16{0x34, 0x36, 0x38, 0x3a}
+	// sub segments are optional, older encoders leave them out.
 	if isIn(subSegIDs, uint16(dscptr.SegmentationTypeID)) {
-		//dscptr.SubSegmentNum = bd.uInt8(8)
-		//dscptr.SubSegmentsExpected = bd.uInt8(8)
-		dscptr.SubSegmentNum = 0
-		dscptr.SubSegmentsExpected = 0
+		if bd.idx+16 > end {
+			dscptr.SubSegmentsOmitted = true
+			return
+		}
+		dscptr.SubSegmentNum = bd.uInt8(8)
+		dscptr.SubSegmentsExpected = bd.uInt8(8)
 	}
 }
 
+// identifier returns dscptr.Identifier, or CUEI if it is not set.
+func (dscptr *Descriptor) identifier() uint32 {
+	if dscptr.Identifier == 0 {
+		return cueIdentifier
+	}
+	return dscptr.Identifier
+}
+
+// encodedLen returns the descriptor length Encode writes for dscptr, the identifier and body.
+func (dscptr *Descriptor) encodedLen() int {
+	bf := &bitEncoder{}
+	bf.Add(1, 8) //bumper to keep leading zeros
+	dscptr.Encode(bf)
+	// +4 for identifier and -1 for the bumper.
+	return len(bf.Bites.Bytes()) + 3
+}
+
 func (dscptr *Descriptor) Encode(be *bitEncoder) {
+	codec, ok := codecFor(dscptr.Tag, dscptr.identifier())
+	if ok {
+		body := codec.Encode(*dscptr)
+		be.AddBytes(body, uint(len(body))<<3)
+		return
+	}
 	switch dscptr.Tag {
 	case 0x2:
 		dscptr.encodeSegmentationDescriptor(be)
 	case 0x0:
 		be.Add(uint32(dscptr.ProviderAvailID), 32)
+	case 0x1:
+		dscptr.encodeDtmfDescriptor(be)
+	case 0x3:
+		dscptr.encodeTimeDescriptor(be)
+	case 0x4:
+		dscptr.encodeAudioDescriptor(be)
+	default:
+		be.AddBytes(dscptr.RawBytes, uint(len(dscptr.RawBytes))<<3)
 	}
+	be.AddBytes(dscptr.Trailing, uint(len(dscptr.Trailing))<<3)
 }
 
 // Encode for Avail Descriptors
@@ -237,11 +320,44 @@ func (dscptr *Descriptor) encodeAvailDescriptor(be *bitEncoder) {
 	be.Add(uint32(dscptr.ProviderAvailID), 32)
 }
 
+// Encode for DTMF Descriptors, DTMFCount is at most 7 chars.
+func (dscptr *Descriptor) encodeDtmfDescriptor(be *bitEncoder) {
+	count := dscptr.DTMFCount & 0x7
+	be.Add(dscptr.PreRoll, 8)
+	be.Add(count, 3)
+	be.Reserve(5)
+	if count > 0 {
+		be.Add(dscptr.DTMFChars, uint(count)<<3)
+	}
+}
+
+// Encode for Time Descriptors
+func (dscptr *Descriptor) encodeTimeDescriptor(be *bitEncoder) {
+	be.Add(dscptr.TAISeconds&(1<<48-1), 48)
+	be.Add(dscptr.TAINano, 32)
+	be.Add(dscptr.UTCOffset, 16)
+}
+
+// Encode for Audio Descriptors, there are at most 15 AudioComponents.
+func (dscptr *Descriptor) encodeAudioDescriptor(be *bitEncoder) {
+	count := len(dscptr.AudioComponents) & 0xf
+	be.Add(count, 4)
+	be.Reserve(4)
+	for _, comp := range dscptr.AudioComponents[:count] {
+		be.Add(comp.ComponentTag, 8)
+		be.Add(comp.ISOCode&0xffffff, 24)
+		be.Add(comp.BitstreamMode&0x7, 3)
+		be.Add(comp.NumChannels&0xf, 4)
+		be.Add(comp.FullSrvcAudio, 1)
+	}
+}
+
 // Encode a segmentation descriptor
 func (dscptr *Descriptor) encodeSegmentationDescriptor(be *bitEncoder) {
 	be.AddHex64(dscptr.SegmentationEventID, 32)
 	be.Add(dscptr.SegmentationEventCancelIndicator, 1)
-	be.Reserve(7)
+	be.Add(dscptr.EventIDComplianceIndicator, 1)
+	be.Reserve(6)
 	if !dscptr.SegmentationEventCancelIndicator {
 		dscptr.encodeFlags(be)
 		if !dscptr.ProgramSegmentationFlag {
@@ -252,11 +368,11 @@ func (dscptr *Descriptor) encodeSegmentationDescriptor(be *bitEncoder) {
 }
 
 func (dscptr *Descriptor) encodeComponents(be *bitEncoder) {
-	count := uint8(len(dscptr.Components))
+	count := uint8(len(dscptr.SegComponents))
 	be.Add(count, 8)
 	cc := uint8(0)
 	for cc < count {
-		comp := dscptr.Components[cc]
+		comp := dscptr.SegComponents[cc]
 		be.Add(comp.ComponentTag, 8)
 		be.Reserve(7)
 		be.Add(comp.PtsOffset, 33)
@@ -270,35 +386,58 @@ func (dscptr *Descriptor) encodeFlags(be *bitEncoder) {
 	be.Add(dscptr.DeliveryNotRestrictedFlag, 1)
 	if !dscptr.DeliveryNotRestrictedFlag {
 		be.Add(dscptr.WebDeliveryAllowedFlag, 1)
-		be.Add(dscptr.NoRegionalBlackoutFlag, 1)
+		noBlackout := dscptr.NoRegionalBlackoutFlag
+		if dscptr.RegionalBlackout != nil {
+			// no_regional_blackout_flag is 1 when there is no blackout
+			noBlackout = !*dscptr.RegionalBlackout
+		}
+		be.Add(noBlackout, 1)
 		be.Add(dscptr.ArchiveAllowedFlag, 1)
-		//   a_key = k_by_v(table20, dscptr.device_restrictions)
-		//     nbin.add_int(a_key, 2)
-		be.Add(3, 2) //  dscptr.device_restrictions
+		be.Add(deviceRestrictions(dscptr.DeviceRestrictions), 2)
 	} else {
 		be.Reserve(5)
 	}
 }
 
+// deviceRestrictions returns the table20 key for restrictions, 0x3 if it is not found.
+func deviceRestrictions(restrictions string) uint8 {
+	for k, v := range table20 {
+		if v == restrictions {
+			return k
+		}
+	}
+	return 0x3
+}
+
 func (dscptr *Descriptor) encodeSegmentation(be *bitEncoder) {
 	if dscptr.SegmentationDurationFlag {
 		be.Add(float64(dscptr.SegmentationDuration), 40)
 	}
+	upid := dscptr.upidBytes()
 	be.Add(dscptr.SegmentationUpidType, 8)
-	be.Add(dscptr.SegmentationUpidLength, 8)
-	//be.Reserve(int(dscptr.SegmentationUpidLength <<3))
-	if dscptr.SegmentationUpidLength > 0 {
-		dscptr.SegmentationUpid.Encode(be, dscptr.SegmentationUpidType)
-	}
+	// upid_length is the length of the upid bytes, not SegmentationUpidLength
+	be.Add(uint8(len(upid)), 8)
+	be.AddBytes(upid, uint(len(upid))<<3)
 	be.Add(dscptr.SegmentationTypeID, 8)
 	dscptr.encodeSegments(be)
 }
 
+// upidBytes returns the encoded SegmentationUpid, or UpidBytes when it has not been parsed.
+func (dscptr *Descriptor) upidBytes() []byte {
+	if dscptr.SegmentationUpid == nil {
+		return dscptr.UpidBytes
+	}
+	bf := &bitEncoder{}
+	bf.Add(1, 8) //bumper to keep leading zeros
+	dscptr.SegmentationUpid.Encode(bf, dscptr.SegmentationUpidType)
+	return bf.Bites.Bytes()[1:]
+}
+
 func (dscptr *Descriptor) encodeSegments(be *bitEncoder) {
 	be.Add(dscptr.SegmentNum, 8)
 	be.Add(dscptr.SegmentsExpected, 8)
 	subSegIDs := []uint16{0x34, 0x36, 0x38, 0x3a}
-	if isIn(subSegIDs, uint16(dscptr.SegmentationTypeID)) {
+	if isIn(subSegIDs, uint16(dscptr.SegmentationTypeID)) && !dscptr.SubSegmentsOmitted {
 		be.Add(dscptr.SubSegmentNum, 8)
 		be.Add(dscptr.SubSegmentsExpected, 8)
 	}
@@ -0,0 +1,367 @@
+package cuei_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestDescriptorRoundTrip(t *testing.T) {
+	tests := []cuei.Descriptor{
+		{Tag: 0x1, PreRoll: 177, DTMFCount: 1, DTMFChars: '1'},
+		{Tag: 0x1, PreRoll: 10, DTMFCount: 4, DTMFChars: 0x31323334},
+		{Tag: 0x3, TAISeconds: 1 << 40, TAINano: 999999999, UTCOffset: 37},
+		{Tag: 0x4, AudioComponents: []cuei.AudioComponent{
+			{ComponentTag: 1, ISOCode: 0x656e67, BitstreamMode: 2, NumChannels: 5, FullSrvcAudio: true},
+			{ComponentTag: 2, ISOCode: 0x737061, BitstreamMode: 0, NumChannels: 2},
+		}},
+	}
+	for _, dscptr := range tests {
+		cue := withDescriptors(dscptr)
+		for i := 0; i < 2; i++ {
+			// the second pass encodes the decoded Cue again
+			cue = roundTrip(t, cue)
+			if len(cue.Descriptors) != 1 || len(cue.Warnings) != 0 {
+				t.Fatalf("tag %#x decoded %v descriptors, warnings %v", dscptr.Tag, len(cue.Descriptors), cue.Warnings)
+			}
+			got := cue.Descriptors[0]
+			if fmt.Sprint(got.PreRoll, got.DTMFCount, got.DTMFChars, got.TAISeconds, got.TAINano, got.UTCOffset, got.AudioComponents) !=
+				fmt.Sprint(dscptr.PreRoll, dscptr.DTMFCount, dscptr.DTMFChars, dscptr.TAISeconds, dscptr.TAINano, dscptr.UTCOffset, dscptr.AudioComponents) {
+				t.Errorf("tag %#x round trip is %+v, want %+v", dscptr.Tag, got, dscptr)
+			}
+		}
+		if err := cue.Validate(); err != nil {
+			t.Errorf("tag %#x: %v", dscptr.Tag, err)
+		}
+		if !cuei.NewCue().Decode(cue.Encode2B64()) {
+			t.Errorf("tag %#x re-encoded cue does not decode", dscptr.Tag)
+		}
+	}
+}
+
+func TestSegmentationDuration(t *testing.T) {
+	flagged := segmentation(0x34)
+	flagged.SegmentationDurationFlag = true
+	flagged.SegmentationDuration = 60.0
+	unflagged := segmentation(0x35)
+	unflagged.SegmentationDuration = 60.0 // not encoded without the flag
+
+	cue := roundTrip(t, withDescriptors(flagged, unflagged))
+	if len(cue.Descriptors) != 2 {
+		t.Fatalf("decoded %v descriptors, want 2", len(cue.Descriptors))
+	}
+	got := cue.Descriptors[0]
+	if !got.HasDuration() || got.SegmentationDuration != 60.0 {
+		t.Errorf("flagged duration is %v, %v", got.HasDuration(), got.SegmentationDuration)
+	}
+	got = cue.Descriptors[1]
+	if got.HasDuration() || got.SegmentationDuration != 0.0 {
+		t.Errorf("unflagged duration is %v, %v", got.HasDuration(), got.SegmentationDuration)
+	}
+	if got.SegmentationTypeID != 0x35 {
+		t.Errorf("unflagged SegmentationTypeID is %#x, want 0x35", got.SegmentationTypeID)
+	}
+}
+
+func TestSegmentationComponents(t *testing.T) {
+	dscptr := segmentation(0x30)
+	dscptr.ProgramSegmentationFlag = false
+	dscptr.SegComponents = []cuei.SegComponent{
+		{ComponentTag: 0x1, PtsOffset: 0.5},
+		{ComponentTag: 0x2, PtsOffset: 1.0},
+	}
+	dscptr.SegmentNum = 1
+	dscptr.SegmentsExpected = 2
+
+	cue := roundTrip(t, withDescriptors(dscptr))
+	got := cue.Descriptors[0]
+	if got.ProgramSegmentationFlag || len(got.SegComponents) != 2 {
+		t.Fatalf("decoded components %v", got.SegComponents)
+	}
+	for i, comp := range got.SegComponents {
+		if comp != dscptr.SegComponents[i] {
+			t.Errorf("component %v is %v, want %v", i, comp, dscptr.SegComponents[i])
+		}
+	}
+	if got.SegmentationTypeID != 0x30 || got.SegmentNum != 1 || got.SegmentsExpected != 2 {
+		t.Errorf("fields after the components are %#x %v %v", got.SegmentationTypeID, got.SegmentNum, got.SegmentsExpected)
+	}
+}
+
+func TestSegmentationUpidTypeWithoutUpid(t *testing.T) {
+	dscptr := segmentation(0x10)
+	dscptr.SegmentationUpidType = 0x01 // user defined, no upid bytes
+
+	cue := roundTrip(t, withDescriptors(dscptr))
+	got := cue.Descriptors[0]
+	if got.SegmentationUpidType != 0x01 || got.SegmentationUpidLength != 0 || got.SegmentationUpid != nil {
+		t.Errorf("upid type %#x length %v upid %v", got.SegmentationUpidType, got.SegmentationUpidLength, got.SegmentationUpid)
+	}
+}
+
+func TestDescriptorLengthMismatch(t *testing.T) {
+	// an Avail Descriptor declaring 9 bytes but holding 8 and a stray byte.
+	data := "0xfc3030000000000000fffff014054800008f7feffe7369c02efe0052ccf500000000" +
+		"000b" + "0009" + "43554549" + "00000135" + "00" + "62dba30a"
+	cue, err := cuei.NewDecoder().Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "descriptor tag 0x0 length is 9 bytes, 8 were decoded (-1)"
+	if len(cue.Warnings) != 1 || cue.Warnings[0] != want {
+		t.Errorf("warnings are %v, want %v", cue.Warnings, want)
+	}
+	if cue.Descriptors[0].ProviderAvailID != 309 || cue.Crc32 != 0x62dba30a {
+		t.Errorf("ProviderAvailID %v Crc32 %#x", cue.Descriptors[0].ProviderAvailID, cue.Crc32)
+	}
+}
+
+func TestCanonicalizeDescriptors(t *testing.T) {
+	dtmf := cuei.Descriptor{Tag: 0x1, PreRoll: 177, DTMFCount: 2, DTMFChars: 0x3132}
+	tai := cuei.Descriptor{Tag: 0x3, TAISeconds: 1700000000, TAINano: 500, UTCOffset: 37}
+	audio := cuei.Descriptor{Tag: 0x4, AudioComponents: []cuei.AudioComponent{
+		{ComponentTag: 1, ISOCode: 0x656e67, BitstreamMode: 2, NumChannels: 5, FullSrvcAudio: true},
+	}}
+	for _, dscptr := range []cuei.Descriptor{dtmf, tai, audio} {
+		cue, err := cuei.NewDecoder().Decode(withDescriptors(dscptr).Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := cue.Descriptors[0].Json()
+		cue.Canonicalize()
+		if len(cue.Descriptors) != 1 || cue.Descriptors[0].Json() != before {
+			t.Errorf("tag %#x canonicalized to %v, want %v", dscptr.Tag, cue.Descriptors, before)
+		}
+	}
+}
+
+func TestDescriptorLengthPastLoop(t *testing.T) {
+	// an Avail Descriptor declaring 12 bytes in a 10 byte descriptor loop.
+	data := "0xfc302f000000000000fffff014054800008f7feffe7369c02efe0052ccf500000000" +
+		"000a" + "000c" + "43554549" + "00000135" + "62dba30a"
+	cue, err := cuei.NewDecoder().Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "descriptor tag 0x0 length 12 is more than the 8 bytes left in the loop"
+	if len(cue.Warnings) != 1 || cue.Warnings[0] != want {
+		t.Errorf("warnings are %v, want %v", cue.Warnings, want)
+	}
+	if len(cue.Descriptors) != 1 || cue.Descriptors[0].ProviderAvailID != 309 || cue.Crc32 != 0x62dba30a {
+		t.Errorf("descriptors %+v Crc32 %#x", cue.Descriptors, cue.Crc32)
+	}
+}
+
+func TestSegmentationDeliveryFlags(t *testing.T) {
+	unrestricted := segmentation(0x30)
+	restricted := segmentation(0x30)
+	restricted.DeliveryNotRestrictedFlag = false
+	restricted.WebDeliveryAllowedFlag = true
+	restricted.ArchiveAllowedFlag = true
+	restricted.DeviceRestrictions = "Restrict Group 1"
+	// the flags are one byte either way, the restriction bits replace the reserved bits.
+	for _, dscptr := range []cuei.Descriptor{unrestricted, restricted} {
+		cue := withDescriptors(dscptr)
+		dll := cue.Dll
+		cue2 := roundTrip(t, cue)
+		if cue2.Dll != dll || cue2.Descriptors[0].Length != 15 {
+			t.Errorf("Dll %v became %v, descriptor length %v", dll, cue2.Dll, cue2.Descriptors[0].Length)
+		}
+		got := cue2.Descriptors[0]
+		if got.WebDeliveryAllowedFlag != dscptr.WebDeliveryAllowedFlag || got.DeviceRestrictions != dscptr.DeviceRestrictions {
+			t.Errorf("flags decoded as %+v", got)
+		}
+	}
+}
+
+// midSegmentation returns a Segmentation Descriptor with a MID of n URIs of uriLen bytes.
+func midSegmentation(n int, uriLen int) cuei.Descriptor {
+	dscptr := segmentation(0x34)
+	dscptr.SegmentationUpidType = 0x0d
+	dscptr.SegmentationUpid = &cuei.Upid{}
+	for i := 0; i < n; i++ {
+		uri := fmt.Sprintf("urn:%v:%v", i, strings.Repeat("x", uriLen-6))
+		dscptr.SegmentationUpid.Upids = append(dscptr.SegmentationUpid.Upids, cuei.Upid{UpidType: 0x0f, Value: uri})
+	}
+	dscptr.SegmentationUpidLength = uint8(n * (2 + uriLen))
+	return dscptr
+}
+
+func TestLargeMID(t *testing.T) {
+	// 17 + 4 * (2 + 57) is 253 bytes, the longest a 0x34 descriptor can hold is 255.
+	cue := withDescriptors(midSegmentation(4, 57), segmentation(0x10))
+	bites, err := cue.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cue2, err := cuei.NewDecoder().Decode(bites)
+	if err != nil || len(cue2.Warnings) > 0 {
+		t.Fatalf("decode: %v %v", err, cue2.Warnings)
+	}
+	if cue2.Descriptors[0].Length != 253 || len(cue2.UPIDs()) != 4 || cue2.InfoSection.SectionLength < 256 {
+		t.Errorf("descriptor length %v, %v upids, section length %v",
+			cue2.Descriptors[0].Length, len(cue2.UPIDs()), cue2.InfoSection.SectionLength)
+	}
+
+	cue = withDescriptors(midSegmentation(4, 58))
+	if _, err = cue.MarshalBinary(); err == nil || !strings.Contains(err.Error(), "257 bytes") {
+		t.Errorf("MarshalBinary error is %v", err)
+	}
+}
+
+func TestDescriptorTrailing(t *testing.T) {
+	dscptr := segmentation(0x30)
+	dscptr.Trailing = []byte{0xde, 0xad}
+	cue := roundTrip(t, withDescriptors(dscptr, segmentation(0x31)))
+	got := cue.Descriptors[0]
+	if !bytes.Equal(got.Trailing, dscptr.Trailing) || got.Length != 17 {
+		t.Errorf("trailing bytes %x, length %v", got.Trailing, got.Length)
+	}
+	if cue.Descriptors[1].SegmentationTypeID != 0x31 || cue.Descriptors[1].Trailing != nil {
+		t.Errorf("next descriptor is %+v", cue.Descriptors[1])
+	}
+	if cue2 := roundTrip(t, cue); !bytes.Equal(cue2.Descriptors[0].Trailing, dscptr.Trailing) {
+		t.Errorf("re-encoded trailing bytes are %x", cue2.Descriptors[0].Trailing)
+	}
+}
+
+func TestRepeatedAvailDescriptors(t *testing.T) {
+	first := cuei.Descriptor{Tag: 0x0, ProviderAvailID: 0x135}
+	second := cuei.Descriptor{Tag: 0x0, ProviderAvailID: 0x136}
+	cue := roundTrip(t, withDescriptors(first, second))
+	if len(cue.Descriptors) != 2 || cue.Dll != 20 {
+		t.Fatalf("decoded %v descriptors in %v bytes", len(cue.Descriptors), cue.Dll)
+	}
+	for i, want := range []uint32{0x135, 0x136} {
+		if got := cue.Descriptors[i]; got.Tag != 0x0 || got.ProviderAvailID != want {
+			t.Errorf("descriptor %v is tag %#x avail id %#x, want %#x", i, got.Tag, got.ProviderAvailID, want)
+		}
+	}
+}
+
+func TestDescriptorLoopBounds(t *testing.T) {
+	good := withDescriptors(segmentation(0x34))
+	corrupt := func(i int, v uint16) []byte {
+		bites := append([]byte(nil), good.Encode()...)
+		bites[i] = bites[i]&0xf0 | byte(v>>8)
+		bites[i+1] = byte(v)
+		return bites
+	}
+	// descriptor_loop_length at byte 19, past the crc32
+	cue, err := cuei.NewDecoder().Decode(corrupt(19, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cue.Dll != good.Dll || len(cue.Descriptors) != 1 || cue.Crc32 != good.Crc32 || len(cue.Warnings) != 1 {
+		t.Errorf("bad dll decoded dll %v crc32 %#x warnings %v", cue.Dll, cue.Crc32, cue.Warnings)
+	}
+	// section_length at byte 1, past the end of the bytes
+	cue, err = cuei.NewDecoder().Decode(corrupt(1, 0xfff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cue.Dll != good.Dll || len(cue.Descriptors) != 1 || cue.Crc32 != good.Crc32 || len(cue.Warnings) != 0 {
+		t.Errorf("long section length decoded dll %v crc32 %#x warnings %v", cue.Dll, cue.Crc32, cue.Warnings)
+	}
+	// section_length 8 bytes short, into the descriptor loop
+	cue, err = cuei.NewDecoder().Decode(corrupt(1, good.InfoSection.SectionLength-8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cue.Dll != good.Dll-8 || len(cue.Warnings) == 0 {
+		t.Errorf("short section length decoded dll %v warnings %v", cue.Dll, cue.Warnings)
+	}
+	dec := cuei.NewDecoder()
+	dec.Strict = true
+	if _, err := dec.Decode(corrupt(19, 200)); err == nil {
+		t.Error("strict decode of a bad dll did not fail")
+	}
+}
+
+func TestRegionalBlackout(t *testing.T) {
+	for _, blackout := range []bool{true, false} {
+		blackout := blackout
+		dscptr := segmentation(0x30)
+		dscptr.DeliveryNotRestrictedFlag = false
+		dscptr.NoRegionalBlackoutFlag = blackout // RegionalBlackout is encoded instead
+		dscptr.RegionalBlackout = &blackout
+		cue := withDescriptors(dscptr)
+		// byte 32 is the segmentation flags, no_regional_blackout_flag is bit 3
+		flags := cue.Encode()[32]
+		if wire := flags&0x08 != 0; wire == blackout {
+			t.Errorf("regional blackout %v wrote no_regional_blackout_flag %v", blackout, wire)
+		}
+		if cue.Descriptors[0].NoRegionalBlackoutFlag != blackout {
+			t.Errorf("Encode changed NoRegionalBlackoutFlag to %v", !blackout)
+		}
+		got := roundTrip(t, cue).Descriptors[0]
+		if got.RegionalBlackout == nil || *got.RegionalBlackout != blackout || got.NoRegionalBlackoutFlag == blackout {
+			t.Errorf("regional blackout %v decoded as %v, flag %v", blackout, got.RegionalBlackout, got.NoRegionalBlackoutFlag)
+		}
+	}
+}
+
+func TestNoRegionalBlackoutFlag(t *testing.T) {
+	// old JSON has only the wire flag, false is a blackout
+	var dscptr cuei.Descriptor
+	js := `{"Tag": 2, "SegmentationEventID": "0x1", "ProgramSegmentationFlag": true, "SegmentationTypeID": 48}`
+	if err := json.Unmarshal([]byte(js), &dscptr); err != nil {
+		t.Fatal(err)
+	}
+	got := roundTrip(t, withDescriptors(dscptr)).Descriptors[0]
+	if got.NoRegionalBlackoutFlag || got.RegionalBlackout == nil || !*got.RegionalBlackout {
+		t.Errorf("blackout decoded as flag %v, regional blackout %v", got.NoRegionalBlackoutFlag, got.RegionalBlackout)
+	}
+}
+
+func TestSegmentationUpidLength(t *testing.T) {
+	eidr := "10.5240/7791-8534-2C23-9030-8610-5"
+	upids := []*cuei.Upid{
+		{Name: "EIDR", UpidType: 0x0a, Value: eidr},
+		{Name: "MPU", UpidType: 0x0c, FormatIdentifier: "0x41424344", PrivateData: []byte("data")},
+		{Name: "MID", UpidType: 0x0d, Upids: []cuei.Upid{{UpidType: 0x0a, Value: eidr}, {UpidType: 0x0f, Value: "https://example.com"}}},
+	}
+	for _, upid := range upids {
+		for _, length := range []uint8{0, uint8(len(upid.Value))} {
+			dscptr := segmentation(0x34)
+			dscptr.SegmentationUpidType = upid.UpidType
+			dscptr.SegmentationUpidLength = length
+			dscptr.SegmentationUpid = upid
+			cue := roundTrip(t, withDescriptors(dscptr))
+			if len(cue.Warnings) > 0 {
+				t.Errorf("upid type %#x length %v: warnings %v", upid.UpidType, length, cue.Warnings)
+			}
+			got := cue.Descriptors[0]
+			if got.SegmentationUpid == nil || got.SegmentationUpid.Value != upid.Value || got.SegmentationTypeID != 0x34 {
+				t.Errorf("upid type %#x length %v decoded as %+v", upid.UpidType, length, got.SegmentationUpid)
+			}
+		}
+	}
+}
+
+func TestSubSegmentsOmitted(t *testing.T) {
+	full := withDescriptors(segmentation(0x34)).Encode()
+	dscptr := segmentation(0x34)
+	dscptr.SubSegmentsOmitted = true
+	short := withDescriptors(dscptr).Encode()
+	if len(short) != len(full)-2 {
+		t.Fatalf("short form is %v bytes, want %v", len(short), len(full)-2)
+	}
+	cue := cuei.NewCue()
+	cue.Decode(short)
+	if !cue.Descriptors[0].SubSegmentsOmitted || len(cue.Warnings) > 0 {
+		t.Errorf("short form decoded as %+v, warnings %v", cue.Descriptors[0], cue.Warnings)
+	}
+	if got := cue.Encode(); !bytes.Equal(got, short) {
+		t.Errorf("short form re-encodes to %x, want %x", got, short)
+	}
+	cue = cuei.NewCue()
+	cue.Decode(full)
+	if cue.Descriptors[0].SubSegmentsOmitted {
+		t.Error("full form decoded with SubSegmentsOmitted")
+	}
+}
@@ -0,0 +1,145 @@
+package cuei
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// emsgScheme is the emsg scheme_id_uri for binary SCTE-35.
+const emsgScheme = "urn:scte:scte35:2013:bin"
+
+/*
+EMSGTiming is the timing of an emsg box.
+
+	Version 0 boxes set PresentationTimeDelta,
+	version 1 boxes set PresentationTime.
+*/
+type EMSGTiming struct {
+	Version               uint8
+	SchemeIDURI           string
+	Value                 string `json:",omitempty"`
+	Timescale             uint32
+	PresentationTime      uint64 `json:",omitempty"`
+	PresentationTimeDelta uint32 `json:",omitempty"`
+	EventDuration         uint32
+	ID                    uint32
+}
+
+/*
+FromEMSG decodes SCTE-35 carried in emsg boxes.
+
+	box holds one or more emsg boxes, version 0 or version 1.
+	The Cue is decoded from the message_data of the first box
+	with the urn:scte:scte35:2013:bin scheme,
+	an EMSGTiming is returned for each box with that scheme.
+*/
+func FromEMSG(box []byte) (*Cue, []EMSGTiming, error) {
+	var cue *Cue
+	var timings []EMSGTiming
+	for len(box) > 0 {
+		size, body, err := emsgBody(box)
+		if err != nil {
+			return cue, timings, err
+		}
+		timing, data, err := parseEMSG(body)
+		if err != nil {
+			return cue, timings, err
+		}
+		box = box[size:]
+		if timing.SchemeIDURI != emsgScheme {
+			continue
+		}
+		timings = append(timings, timing)
+		if cue == nil {
+			cue, err = NewDecoder().Decode(data)
+			if err != nil {
+				return cue, timings, err
+			}
+		}
+	}
+	if cue == nil {
+		return nil, nil, errors.New("no emsg box with the " + emsgScheme + " scheme")
+	}
+	return cue, timings, nil
+}
+
+// emsgBody checks the box header and returns the box size and the body after the header.
+func emsgBody(box []byte) (uint64, []byte, error) {
+	if len(box) < 8 {
+		return 0, nil, errors.New("emsg box is truncated")
+	}
+	size := uint64(binary.BigEndian.Uint32(box))
+	head := uint64(8)
+	if size == 1 {
+		if len(box) < 16 {
+			return 0, nil, errors.New("emsg box is truncated")
+		}
+		size = binary.BigEndian.Uint64(box[8:])
+		head = 16
+	}
+	if string(box[4:8]) != "emsg" {
+		return 0, nil, fmt.Errorf("box type is %q, not emsg", box[4:8])
+	}
+	if size < head || size > uint64(len(box)) {
+		return 0, nil, fmt.Errorf("emsg box size %v is wrong for %v bytes", size, len(box))
+	}
+	return size, box[head:size], nil
+}
+
+// parseEMSG parses an emsg full box body and returns the timing and message_data.
+func parseEMSG(body []byte) (EMSGTiming, []byte, error) {
+	var timing EMSGTiming
+	short := errors.New("emsg box is truncated")
+	if len(body) < 4 {
+		return timing, nil, short
+	}
+	timing.Version = body[0]
+	body = body[4:] // version and flags
+	var ok bool
+	switch timing.Version {
+	case 0:
+		if timing.SchemeIDURI, body, ok = cString(body); !ok {
+			return timing, nil, short
+		}
+		if timing.Value, body, ok = cString(body); !ok {
+			return timing, nil, short
+		}
+		if len(body) < 16 {
+			return timing, nil, short
+		}
+		timing.Timescale = binary.BigEndian.Uint32(body)
+		timing.PresentationTimeDelta = binary.BigEndian.Uint32(body[4:])
+		timing.EventDuration = binary.BigEndian.Uint32(body[8:])
+		timing.ID = binary.BigEndian.Uint32(body[12:])
+		body = body[16:]
+	case 1:
+		if len(body) < 20 {
+			return timing, nil, short
+		}
+		timing.Timescale = binary.BigEndian.Uint32(body)
+		timing.PresentationTime = binary.BigEndian.Uint64(body[4:])
+		timing.EventDuration = binary.BigEndian.Uint32(body[12:])
+		timing.ID = binary.BigEndian.Uint32(body[16:])
+		body = body[20:]
+		if timing.SchemeIDURI, body, ok = cString(body); !ok {
+			return timing, nil, short
+		}
+		if timing.Value, body, ok = cString(body); !ok {
+			return timing, nil, short
+		}
+	default:
+		return timing, nil, fmt.Errorf("emsg version %v is not supported", timing.Version)
+	}
+	return timing, body, nil
+}
+
+// cString splits a null terminated string from the front of b.
+func cString(b []byte) (string, []byte, bool) {
+	idx := bytes.IndexByte(b, 0)
+	if idx == -1 {
+		return "", b, false
+	}
+	return string(b[:idx]), b[idx+1:], true
+}
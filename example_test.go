@@ -1,8 +1,11 @@
 package cuei_test
 
 import (
+	"encoding/base64"
 	"fmt"
 	"github.com/futzu/cuei"
+	"os"
+	"strings"
 	"testing"
 )
 
@@ -88,6 +91,20 @@ func ExampleCue_Encode2Hex() {
 	cue.Show()
 }
 
+func ExampleCue_Encode2BigInt() {
+	data := "/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q=="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	b := cue.Encode2BigInt()
+	fmt.Println(b)
+	cue2 := cuei.NewCue()
+	cue2.Decode(b.String())
+	fmt.Println(cue2.Encode2B64())
+	// Output:
+	// 1583008701074197245727019716796221242036302348025116111908569
+	// /DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==
+}
+
 func ExampleCue_AdjustPts() {
 	data := "/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q=="
 	cue := cuei.NewCue()
@@ -100,6 +117,219 @@ func ExampleCue_AdjustPts() {
 	fmt.Println("Is", cue.Encode2B64())
 }
 
+func ExampleCue_MakeReturn() {
+	data := "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	ret, err := cue.MakeReturn(21574.852654)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(ret.Command.SpliceEventID, ret.Command.OutOfNetworkIndicator, ret.Command.PTS)
+	fmt.Println(ret.Encode2B64())
+	// Output:
+	// 1207959695 false 21574.852654
+	// /DAqAAAAAAAA///wDwVIAACPf0/+c7yNIwAAAAAACgAIQ1VFSQAAATVerkf3
+}
+
+func ExampleDecoder_Decode() {
+	data := "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	cue.Descriptors[0].Identifier = 0x43554558 // "CUEX"
+	bites := cue.Encode()
+
+	dec := cuei.NewDecoder()
+	lenient, _ := dec.Decode(bites)
+	fmt.Println(lenient.Warnings)
+	dec.Strict = true
+	_, err := dec.Decode(bites)
+	fmt.Println(err)
+	// Output:
+	// [descriptor tag 0x0 identifier is 0x43554558 not 0x43554549 (CUEI)]
+	// descriptor tag 0x0 identifier is 0x43554558 not 0x43554549 (CUEI)
+}
+
+func ExampleDecoder() {
+	dec := cuei.NewDecoder()
+	dec.OnCommand = func(cmd *cuei.Command) {
+		fmt.Println("Command:", cmd.Name)
+	}
+	dec.OnDescriptor = func(dscptr *cuei.Descriptor) {
+		fmt.Println("Descriptor:", dscptr.Name)
+	}
+	dec.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	// Output:
+	// Command: Splice Insert
+	// Descriptor: Avail Descriptor
+}
+
+func ExampleCue_ConformanceReport() {
+	data := "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	cue.Crc32 = 0 // fails the crc32 rule
+	report := cue.ConformanceReport()
+	fmt.Println(report.Level)
+	for _, result := range report.Results {
+		if result.Level != cuei.Pass {
+			fmt.Println(result.Rule, result.Level, result.Message)
+		}
+	}
+	// Output:
+	// fail
+	// crc32 fail crc32 is 0x0, want 0x62dba30a
+}
+
+func ExampleReadCues() {
+	corpus := `# a time signal
+/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==
+
+# a splice insert
+/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=
+`
+	cues, err := cuei.ReadCues(strings.NewReader(corpus))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, cue := range cues {
+		fmt.Println(cue.Command.Name)
+	}
+	cuei.WriteCues(os.Stdout, cues)
+	// Output:
+	// Time Signal
+	// Splice Insert
+	// /DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==
+	// /DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=
+}
+
+func ExampleCue_Fingerprint() {
+	data := "/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q=="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	retrans := cuei.NewCue()
+	retrans.Decode(data)
+	retrans.AdjustPts(2.0)
+	fmt.Println(cue.Fingerprint(false) == retrans.Fingerprint(false))
+	fmt.Println(cue.Fingerprint(true) == retrans.Fingerprint(true))
+	// Output:
+	// true
+	// false
+}
+
+func ExampleCue_Hash64() {
+	cue := cuei.NewCue()
+	cue.Decode("/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==")
+	fmt.Printf("%#x\n", cue.Hash64())
+	// Output:
+	// 0xd9dd2bf1311eb2e8
+}
+
+func ExampleCommand_Summary() {
+	data := "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	fmt.Println(cue.Command.Summary())
+	// Output:
+	// Splice Insert, event 1207959695, out, duration 60.293566s, auto return, pts 21514.559088
+}
+
+func ExampleDescriptor_Summary() {
+	data := "/DCtAAAAAAAAAP/wBQb+Tq9DwQCXAixDVUVJCUvhcH+fAR1QQ1IxXzEyMTYyMTE0MDBXQUJDUkFDSEFFTFJBWSEBAQIsQ1VFSQlL4W9/nwEdUENSMV8xMjE2MjExNDAwV0FCQ1JBQ0hBRUxSQVkRAQECGUNVRUkJTBwVf58BClRLUlIxNjA4NEEQAQECHkNVRUkJTBwWf98AA3clYAEKVEtSUjE2MDg0QSABAdHBXYA="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	for _, dscptr := range cue.Descriptors {
+		fmt.Println(dscptr.Summary())
+	}
+	// Output:
+	// Chapter End, event 0x94be170, UPID(Deprecated)=PCR1_1216211400WABCRACHAELRAY
+	// Program End, event 0x94be16f, UPID(Deprecated)=PCR1_1216211400WABCRACHAELRAY
+	// Program Start, event 0x94c1c15, UPID(Deprecated)=TKRR16084A
+	// Chapter Start, event 0x94c1c16, duration 646s, UPID(Deprecated)=TKRR16084A
+}
+
+func ExampleNewSegmentation() {
+	tmpl := cuei.NewSegmentation(0x34, 0x0f, "https://example.com/ad")
+	for i := uint32(1); i <= 2; i++ {
+		cue := cuei.NewCue()
+		cue.Decode("/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==")
+		cue.AddDescriptor(tmpl.WithEventID(i).WithDuration(30.0))
+		cue2 := cuei.NewCue()
+		cue2.Decode(cue.Encode())
+		fmt.Println(cue2.Descriptors[0].Summary())
+	}
+	fmt.Println(tmpl.Summary())
+	// Output:
+	// Provider Placement Opportunity Start, event 0x1, duration 30s, UPID(URI)=https://example.com/ad
+	// Provider Placement Opportunity Start, event 0x2, duration 30s, UPID(URI)=https://example.com/ad
+	// Provider Placement Opportunity Start, event 0x0, UPID(URI)=https://example.com/ad
+}
+
+func ExampleCommand_RawBytes() {
+	data := "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	fmt.Printf("%x\n", cue.InfoSection.RawBytes)
+	fmt.Printf("%x\n", cue.Command.RawBytes)
+	// Output:
+	// fc302f000000000000fffff01405
+	// 4800008f7feffe7369c02efe0052ccf500000000
+}
+
+func ExampleCue_ShiftTime() {
+	data := "/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q=="
+	cue := cuei.NewCue()
+	cue.Decode(data)
+	cue.ShiftTime(10.0)
+	fmt.Println(cue.Command.PTS)
+	// wraps at 33 bits
+	cue.ShiftTime(95443.717688 - 133.456788)
+	fmt.Println(cue.Command.PTS)
+	// Output:
+	// 133.456788
+	// 0
+}
+
+func ExampleDecodeSection() {
+	data, _ := base64.StdEncoding.DecodeString("/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==")
+	// a pointer field of 2 and two bytes of the previous section
+	framed := append([]byte{0x02, 0xab, 0xcd}, data...)
+	cue, err := cuei.DecodeSection(framed)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(cue.Command.Name, cue.Command.PTS)
+	// Output:
+	// Time Signal 123.456788
+}
+
+func ExampleEncodeBreakDuration() {
+	fmt.Printf("%#x\n", cuei.EncodeBreakDuration(true, 0.0))
+	fmt.Printf("%#x\n", cuei.EncodeBreakDuration(false, 0.0))
+	v := cuei.EncodeBreakDuration(true, 60.293566)
+	fmt.Printf("%#x\n", v)
+	fmt.Println(cuei.DecodeBreakDuration(v))
+	// Output:
+	// 0xfe00000000
+	// 0x7e00000000
+	// 0xfe0052ccf5
+	// true 60.293566
+}
+
+func ExampleCue_IsImmediate() {
+	cue := cuei.NewCue()
+	cue.Decode("/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==")
+	fmt.Println(cue.IsImmediate())
+	cue.Command.TimeSpecifiedFlag = false
+	fmt.Println(cue.IsImmediate())
+	// Output:
+	// false
+	// true
+}
+
 func Test(t *testing.T) {
 
 	t.Run("Json2Cue", func(t *testing.T) {
@@ -124,3 +354,104 @@ func Test(t *testing.T) {
 		ExampleCue_Encode2Hex()
 	})
 }
+
+func ExampleCue_IsNoOp() {
+	bare := cuei.NewCue()
+	bare.Decode("/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==")
+	segmented := cuei.NewCue()
+	segmented.Decode("/DAnAAAAAAAAAP/wBQb+AKmKxwARAg9DVUVJAAAAAH+/AAAQAADJbvMj")
+	fmt.Println(bare.IsNoOp(), segmented.IsNoOp())
+	// Output:
+	// true false
+}
+
+func ExampleStats() {
+	stats := cuei.NewStats()
+	for _, data := range []string{
+		"/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=",
+		"/DAnAAAAAAAAAP/wBQb+AKmKxwARAg9DVUVJAAAAAH+/AAAQAADJbvMj",
+		"/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==",
+	} {
+		cue := cuei.NewCue()
+		cue.Decode(data)
+		stats.Add(cue)
+	}
+	fmt.Print(stats.Report())
+	// Output:
+	// cues: 3
+	// crc32 failures: 0
+	// command 0x5 Splice Insert: 1
+	// command 0x6 Time Signal: 2
+	// segmentation type 0x10 Program Start: 1
+}
+
+func ExampleNewSpliceCancel() {
+	cancel := cuei.NewSpliceCancel(0x4800008f)
+	fmt.Println(cancel.Encode2B64())
+	cue := cuei.NewCue()
+	cue.Decode(cancel.Encode2B64())
+	fmt.Println(cue.Command.SpliceEventID, cue.Command.SpliceEventCancelIndicator, cue.InfoSection.SectionLength)
+	// Output:
+	// /DAWAAAAAAAAAP/wBQVIAACP/wAAzbrAUg==
+	// 1207959695 true 22
+}
+
+func ExampleNewTimeSignalAt() {
+	cue := cuei.NewTimeSignalAt(123.45)
+	fmt.Println(cue.Encode2B64())
+	cue2, _ := cuei.NewDecoder().Decode(cue.Encode2B64())
+	fmt.Println(cue2.Command.PTS, cue2.InfoSection.Tier, len(cue2.Descriptors))
+	// Output:
+	// /DAWAAAAAAAAAP/wBQb+AKmIZAAAYgug6Q==
+	// 123.45 0xfff 0
+}
+
+func ExampleCommand_AsSpliceInsert() {
+	cue := cuei.NewCue()
+	cue.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	if insert, ok := cue.Command.AsSpliceInsert(); ok {
+		fmt.Println(insert.SpliceEventID, insert.BreakDuration)
+	}
+	_, ok := cue.Command.AsTimeSignal()
+	fmt.Println(ok)
+	// Output:
+	// 1207959695 60.293566
+	// false
+}
+
+func ExamplePeekHeader() {
+	bites, _ := base64.StdEncoding.DecodeString("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	tableID, sectionLength, commandType, ok := cuei.PeekHeader(bites)
+	fmt.Printf("%#x %v %#x %v\n", tableID, sectionLength, commandType, ok)
+	_, _, _, ok = cuei.PeekHeader(bites[:13])
+	fmt.Println(ok)
+	// Output:
+	// 0xfc 47 0x5 true
+	// false
+}
+
+func ExampleDescriptor_OpLabel() {
+	dscptr := cuei.Descriptor{Tag: 0x2, SegmentationTypeID: 0x34}
+	fmt.Println(dscptr.OpLabel())
+	cuei.OpLabels[0x34] = "PPO"
+	fmt.Println(dscptr.OpLabel())
+	cuei.OpLabels[0x34] = "CUE-OUT"
+	dscptr.SegmentationTypeID = 0x35
+	fmt.Println(dscptr.OpLabel())
+	// Output:
+	// CUE-OUT
+	// PPO
+	// CUE-IN
+}
+
+func ExampleCue_String() {
+	cue := cuei.NewCue()
+	cue.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	fmt.Println(cue)
+	signal := cuei.NewTimeSignalAt(10.0)
+	signal.AddDescriptor(cuei.NewSegmentation(0x34, 0x0c, "ABCD"))
+	fmt.Println(signal)
+	// Output:
+	// SpliceInsert OUT evt=1207959695 pts=21514.559088 dur=60.293566 autoReturn
+	// TimeSignal pts=10.0 seg="Provider Placement Opportunity Start" upid=0xc
+}
@@ -0,0 +1,250 @@
+package cuei
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// pcap link types
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLinuxSLL = 113
+)
+
+// ethertypes
+const (
+	etherIPv4 = 0x0800
+	etherVLAN = 0x8100
+	etherIPv6 = 0x86dd
+)
+
+// udpProto is the IP protocol number for UDP.
+const udpProto = 17
+
+// maxRecord is the largest pcap record read, the snaplen libpcap uses by default.
+const maxRecord = 262144
+
+// pcapReader reads packet records from a pcap capture.
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	linkType uint32
+	snapLen  uint32
+}
+
+// readHeader reads the pcap global header.
+func (pr *pcapReader) readHeader() error {
+	head := make([]byte, 24)
+	_, err := io.ReadFull(pr.r, head)
+	if err != nil {
+		return err
+	}
+	switch binary.LittleEndian.Uint32(head) {
+	case 0xa1b2c3d4, 0xa1b23c4d:
+		pr.order = binary.LittleEndian
+	case 0xd4c3b2a1, 0x4d3cb2a1:
+		pr.order = binary.BigEndian
+	default:
+		return errors.New("not a pcap capture")
+	}
+	pr.snapLen = pr.order.Uint32(head[16:20])
+	if pr.snapLen == 0 || pr.snapLen > maxRecord {
+		pr.snapLen = maxRecord
+	}
+	pr.linkType = pr.order.Uint32(head[20:24])
+	return nil
+}
+
+/*
+next reads the next packet record and returns the frame.
+The record length comes from the record header,
+so jumbo frames are read whole.
+
+	A record longer than the snaplen of the capture,
+	or than maxRecord, is an error, the capture is corrupt.
+*/
+func (pr *pcapReader) next() ([]byte, error) {
+	head := make([]byte, 16)
+	_, err := io.ReadFull(pr.r, head)
+	if err != nil {
+		return nil, err
+	}
+	size := pr.order.Uint32(head[8:12])
+	if size > pr.snapLen {
+		return nil, fmt.Errorf("pcap record of %v bytes is more than the %v byte snaplen", size, pr.snapLen)
+	}
+	frame := make([]byte, size)
+	_, err = io.ReadFull(pr.r, frame)
+	return frame, err
+}
+
+/*
+udpPayload strips the link, IP and UDP headers from frame,
+flow is the source and destination addresses and ports.
+*/
+func (pr *pcapReader) udpPayload(frame []byte) (payload []byte, flow string) {
+	var ethertype uint16
+	switch pr.linkType {
+	case linkEthernet:
+		if len(frame) < 14 {
+			return nil, ""
+		}
+		ethertype = binary.BigEndian.Uint16(frame[12:14])
+		frame = frame[14:]
+		for ethertype == etherVLAN && len(frame) >= 4 {
+			ethertype = binary.BigEndian.Uint16(frame[2:4])
+			frame = frame[4:]
+		}
+	case linkLinuxSLL:
+		if len(frame) < 16 {
+			return nil, ""
+		}
+		ethertype = binary.BigEndian.Uint16(frame[14:16])
+		frame = frame[16:]
+	case linkNull:
+		if len(frame) < 4 {
+			return nil, ""
+		}
+		frame = frame[4:]
+	case linkRaw:
+	default:
+		return nil, ""
+	}
+	if len(frame) < 1 {
+		return nil, ""
+	}
+	switch {
+	case ethertype == etherIPv4 || (ethertype == 0 && frame[0]>>4 == 4):
+		return ipv4Payload(frame)
+	case ethertype == etherIPv6 || (ethertype == 0 && frame[0]>>4 == 6):
+		return ipv6Payload(frame)
+	}
+	return nil, ""
+}
+
+// ipv4Payload returns the UDP payload and flow of an IPv4 packet, fragments are dropped.
+func ipv4Payload(pkt []byte) ([]byte, string) {
+	if len(pkt) < 20 || pkt[9] != udpProto {
+		return nil, ""
+	}
+	// more fragments flag or a fragment offset
+	if binary.BigEndian.Uint16(pkt[6:8])&0x3fff != 0 {
+		return nil, ""
+	}
+	// the header is at least 5 32 bit words
+	ihl := int(pkt[0]&0xf) << 2
+	if ihl < 20 {
+		return nil, ""
+	}
+	// total_length drops ethernet padding and a captured fcs
+	total := int(binary.BigEndian.Uint16(pkt[2:4]))
+	if total < ihl || total > len(pkt) {
+		return nil, ""
+	}
+	return udpData(pkt[:total], ihl, pkt[12:20])
+}
+
+// ipv6Payload returns the UDP payload and flow of an IPv6 packet without extension headers.
+func ipv6Payload(pkt []byte) ([]byte, string) {
+	if len(pkt) < 40 || pkt[6] != udpProto {
+		return nil, ""
+	}
+	total := 40 + int(binary.BigEndian.Uint16(pkt[4:6]))
+	if total > len(pkt) {
+		return nil, ""
+	}
+	return udpData(pkt[:total], 40, pkt[8:40])
+}
+
+/*
+udpData strips the 8 byte UDP header starting at idx, the flow is addrs and the ports.
+
+	The payload is udp_length - 8 bytes, a datagram past the end of pkt is skipped.
+*/
+func udpData(pkt []byte, idx int, addrs []byte) ([]byte, string) {
+	if len(pkt) < idx+8 {
+		return nil, ""
+	}
+	length := int(binary.BigEndian.Uint16(pkt[idx+4 : idx+6]))
+	if length < 8 || idx+length > len(pkt) {
+		return nil, ""
+	}
+	return pkt[idx+8 : idx+length], string(addrs) + string(pkt[idx:idx+4])
+}
+
+/*
+ParsePCAP reads MPEGTS over UDP from a pcap capture
+and sends the SCTE-35 Cues found on pids passing filter.
+
+	Ethernet, VLAN, Linux cooked, loopback and raw IP
+	link types are supported for IPv4 and IPv6.
+	Each UDP flow, by source and destination address and port,
+	is parsed by its own Stream, so multicast groups do not mix.
+	Datagrams are parsed in capture order,
+	out of order datagrams are not reordered
+	and fragmented IP packets are skipped.
+	The payload is cut to the UDP length,
+	so Ethernet padding and a captured FCS are not parsed as MPEGTS.
+
+The returned channel is closed when r is exhausted or a record can not be read,
+use ParsePCAPContext to cancel and to get the read error.
+*/
+func ParsePCAP(r io.Reader, filter PIDFilter) (<-chan *Cue, error) {
+	cues, _, err := ParsePCAPContext(context.Background(), r, filter)
+	return cues, err
+}
+
+/*
+ParsePCAPContext is ParsePCAP, the channel is also closed when ctx is done.
+
+	readErr returns the error that ended reading, nil when r was exhausted,
+	call it after the channel is closed.
+*/
+func ParsePCAPContext(ctx context.Context, r io.Reader, filter PIDFilter) (cues <-chan *Cue, readErr func() error, err error) {
+	pr := &pcapReader{r: r}
+	err = pr.readHeader()
+	if err != nil {
+		return nil, nil, err
+	}
+	out := make(chan *Cue)
+	var rerr error
+	go func() {
+		defer close(out)
+		streams := map[string]*Stream{}
+		for ctx.Err() == nil {
+			frame, err := pr.next()
+			if err != nil {
+				if err != io.EOF {
+					rerr = err
+				}
+				return
+			}
+			payload, flow := pr.udpPayload(frame)
+			if payload == nil {
+				continue
+			}
+			stream, ok := streams[flow]
+			if !ok {
+				stream = NewStream()
+				stream.Quiet = true
+				streams[flow] = stream
+			}
+			for _, cue := range stream.DecodeBytes(payload) {
+				if !filter.allows(cue.PacketData.Pid) {
+					continue
+				}
+				select {
+				case out <- cue:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, func() error { return rerr }, nil
+}
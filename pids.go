@@ -47,3 +47,12 @@ func (pids *Pids) delScte35Pid(pid uint16) {
 
 	pids.Scte35Pids = pids.Scte35Pids[:n]
 }
+
+// PIDFilter is a list of SCTE-35 pids to keep,
+// an empty PIDFilter keeps every SCTE-35 pid.
+type PIDFilter []uint16
+
+// allows returns true if pid passes the filter.
+func (filter PIDFilter) allows(pid uint16) bool {
+	return len(filter) == 0 || isIn(filter, pid)
+}
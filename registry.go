@@ -0,0 +1,82 @@
+package cuei
+
+import (
+	"fmt"
+)
+
+/*
+DescriptorCodec decodes and encodes a custom Splice Descriptor.
+
+	body is the descriptor after the tag, length and identifier.
+	Tag, Length and Identifier are set by the library after Decode.
+*/
+type DescriptorCodec struct {
+	Decode func(tag uint8, body []byte) (Descriptor, error)
+	Encode func(dscptr Descriptor) []byte
+}
+
+// descriptorCodecs maps a descriptor tag to a DescriptorCodec.
+var descriptorCodecs = map[uint8]DescriptorCodec{}
+
+/*
+RegisterDescriptor adds a DescriptorCodec for tag,
+it is used in place of the built in decoder for tag.
+
+	Register codecs before decoding, the registry is not locked.
+*/
+func RegisterDescriptor(tag uint8, decode func(tag uint8, body []byte) (Descriptor, error), encode func(dscptr Descriptor) []byte) {
+	descriptorCodecs[tag] = DescriptorCodec{decode, encode}
+}
+
+// UnregisterDescriptor removes the DescriptorCodec for tag, the built in decoder is used again.
+func UnregisterDescriptor(tag uint8) {
+	delete(descriptorCodecs, tag)
+}
+
+// privateCodecs maps a private descriptor identifier to a DescriptorCodec.
+var privateCodecs = map[uint32]DescriptorCodec{}
+
+/*
+RegisterPrivate adds a DescriptorCodec for descriptors with identifier,
+so the same tag from different vendors can be decoded distinctly.
+
+	A codec added with RegisterDescriptor for the tag is used first,
+	registering the CUEI identifier has no effect.
+	Descriptors with an identifier that has no codec keep their bytes in RawBytes.
+	Register codecs before decoding, the registry is not locked.
+*/
+func RegisterPrivate(identifier uint32, decode func(tag uint8, body []byte) (Descriptor, error), encode func(dscptr Descriptor) []byte) {
+	privateCodecs[identifier] = DescriptorCodec{decode, encode}
+}
+
+// UnregisterPrivate removes the DescriptorCodec for identifier.
+func UnregisterPrivate(identifier uint32) {
+	delete(privateCodecs, identifier)
+}
+
+// codecFor returns the registered DescriptorCodec for tag and identifier.
+func codecFor(tag uint8, identifier uint32) (DescriptorCodec, bool) {
+	codec, ok := descriptorCodecs[tag]
+	if ok || identifier == cueIdentifier {
+		return codec, ok
+	}
+	codec, ok = privateCodecs[identifier]
+	return codec, ok
+}
+
+// decodeCodec decodes a descriptor with codec.
+func (dscptr *Descriptor) decodeCodec(codec DescriptorCodec, bd *bitDecoder, tag uint8, length uint8) error {
+	dscptr.Tag = tag
+	dscptr.Length = length
+	body := bd.asBytes(dscptr.bodyBits())
+	d, err := codec.Decode(tag, body)
+	if err != nil {
+		dscptr.RawBytes = body
+		return fmt.Errorf("codec failed: %v", err)
+	}
+	d.Tag = tag
+	d.Length = length
+	d.Identifier = dscptr.Identifier
+	*dscptr = d
+	return nil
+}
@@ -0,0 +1,69 @@
+package cuei_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestRegisterDescriptor(t *testing.T) {
+	t.Cleanup(func() { cuei.UnregisterDescriptor(0xf0) })
+	cuei.RegisterDescriptor(0xf0,
+		func(tag uint8, body []byte) (cuei.Descriptor, error) {
+			if len(body) != 2 {
+				return cuei.Descriptor{}, fmt.Errorf("body is %v bytes", len(body))
+			}
+			return cuei.Descriptor{Name: "Vendor Descriptor", ProviderAvailID: uint32(body[0])<<8 | uint32(body[1])}, nil
+		},
+		func(dscptr cuei.Descriptor) []byte {
+			return []byte{byte(dscptr.ProviderAvailID >> 8), byte(dscptr.ProviderAvailID)}
+		})
+	vendor := cuei.Descriptor{Tag: 0xf0, ProviderAvailID: 0x0102}
+	unknown := cuei.Descriptor{Tag: 0xf1, RawBytes: []byte{0x00, 0x00, 0x07}}
+
+	cue := roundTrip(t, withDescriptors(vendor, unknown))
+	if len(cue.Warnings) > 0 {
+		t.Errorf("warnings %v", cue.Warnings)
+	}
+	got := cue.Descriptors[0]
+	if got.Name != "Vendor Descriptor" || got.ProviderAvailID != 0x0102 || got.Length != 6 {
+		t.Errorf("registered tag decoded as %+v", got)
+	}
+	got = cue.Descriptors[1]
+	if !bytes.Equal(got.RawBytes, unknown.RawBytes) || got.Length != 7 {
+		t.Errorf("unknown tag RawBytes %x length %v", got.RawBytes, got.Length)
+	}
+	cuei.UnregisterDescriptor(0xf0)
+	cue = roundTrip(t, withDescriptors(vendor))
+	if got := cue.Descriptors[0]; got.Name == "Vendor Descriptor" {
+		t.Errorf("unregistered tag decoded as %+v", got)
+	}
+}
+
+func TestRegisterPrivate(t *testing.T) {
+	const vend = 0x56454e44 // "VEND"
+	t.Cleanup(func() { cuei.UnregisterPrivate(vend) })
+	cuei.RegisterPrivate(vend,
+		func(tag uint8, body []byte) (cuei.Descriptor, error) {
+			return cuei.Descriptor{Name: "VEND Descriptor", RawBytes: body}, nil
+		},
+		func(dscptr cuei.Descriptor) []byte {
+			return dscptr.RawBytes
+		})
+	vendor := cuei.Descriptor{Tag: 0xf2, Identifier: vend, RawBytes: []byte{0x01}}
+	other := cuei.Descriptor{Tag: 0xf2, Identifier: 0x41434d45, RawBytes: []byte{0x02}} // "ACME"
+
+	cue := roundTrip(t, withDescriptors(vendor, other))
+	if got := cue.Descriptors[0]; got.Name != "VEND Descriptor" || got.Identifier != vend || !bytes.Equal(got.RawBytes, []byte{0x01}) {
+		t.Errorf("VEND descriptor decoded as %+v", got)
+	}
+	if got := cue.Descriptors[1]; got.Name != "" || !bytes.Equal(got.RawBytes, []byte{0x02}) {
+		t.Errorf("ACME descriptor decoded as %+v", got)
+	}
+	if len(cue.Warnings) != 1 || !strings.Contains(cue.Warnings[0], "0x41434d45") {
+		t.Errorf("warnings are %v", cue.Warnings)
+	}
+}
@@ -0,0 +1,91 @@
+package cuei
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+RTPDepacketizer strips RTP headers from MPEGTS over RTP
+and parses the payloads with Stream.
+
+	A jump in the RTP sequence number is reported to OnWarning,
+	lost packets may explain a Cue that never completes.
+	A duplicate or late packet, one at or behind the last sequence number,
+	is reported and dropped, its payload is not parsed.
+*/
+type RTPDepacketizer struct {
+	Stream    *Stream
+	OnWarning func(warning string) // Called with each sequence gap and each duplicate or late packet.
+	Gaps      int                  // the number of sequence gaps seen
+	Late      int                  // the number of duplicate or late packets dropped
+	seq       uint16
+	started   bool
+}
+
+/*
+Decode parses one RTP packet and returns any Cues completed by its payload.
+
+	The CSRC list, header extension and padding are skipped.
+*/
+func (rtp *RTPDepacketizer) Decode(pkt []byte) ([]*Cue, error) {
+	payload, seq, err := rtpPayload(pkt)
+	if err != nil {
+		return nil, err
+	}
+	if rtp.started {
+		// the signed difference, sequence numbers wrap at 16 bits
+		diff := int16(seq - rtp.seq)
+		if diff <= 0 {
+			rtp.Late++
+			if rtp.OnWarning != nil {
+				rtp.OnWarning(fmt.Sprintf("rtp packet %v is a duplicate or late after %v, dropped", seq, rtp.seq))
+			}
+			return nil, nil
+		}
+		if diff > 1 {
+			rtp.Gaps++
+			if rtp.OnWarning != nil {
+				rtp.OnWarning(fmt.Sprintf("rtp sequence gap, %v packets lost after %v", diff-1, rtp.seq))
+			}
+		}
+	}
+	rtp.seq = seq
+	rtp.started = true
+	return rtp.Stream.DecodeBytes(payload), nil
+}
+
+// rtpPayload returns the payload and sequence number of an RTP packet.
+func rtpPayload(pkt []byte) ([]byte, uint16, error) {
+	short := errors.New("rtp packet is truncated")
+	if len(pkt) < 12 {
+		return nil, 0, short
+	}
+	if pkt[0]>>6 != 2 {
+		return nil, 0, fmt.Errorf("rtp version is %v, not 2", pkt[0]>>6)
+	}
+	seq := uint16(pkt[2])<<8 | uint16(pkt[3])
+	head := 12 + 4*int(pkt[0]&0x0f)
+	if pkt[0]&0x10 != 0 {
+		if len(pkt) < head+4 {
+			return nil, 0, short
+		}
+		head += 4 + 4*(int(pkt[head+2])<<8|int(pkt[head+3]))
+	}
+	end := len(pkt)
+	if pkt[0]&0x20 != 0 {
+		end -= int(pkt[end-1])
+	}
+	if head > end {
+		return nil, 0, short
+	}
+	return pkt[head:end], seq, nil
+}
+
+// initialize and return a *RTPDepacketizer
+func NewRTPDepacketizer() *RTPDepacketizer {
+	rtp := &RTPDepacketizer{}
+	rtp.Stream = NewStream()
+	rtp.Stream.Quiet = true
+	return rtp
+}
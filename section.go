@@ -1,5 +1,43 @@
 package cuei
 
+import "fmt"
+
+// EncryptionAlgorithm is the 6 bit encryption_algorithm of an InfoSection.
+type EncryptionAlgorithm uint8
+
+// encryption_algorithm values, 4 through 31 are reserved and 32 through 63 are user private.
+const (
+	EncryptionNone      EncryptionAlgorithm = 0
+	EncryptionDESECB    EncryptionAlgorithm = 1
+	EncryptionDESCBC    EncryptionAlgorithm = 2
+	EncryptionTripleDES EncryptionAlgorithm = 3
+	encryptionMax       EncryptionAlgorithm = 63
+)
+
+// String returns the name of algo.
+func (algo EncryptionAlgorithm) String() string {
+	switch {
+	case algo == EncryptionNone:
+		return "None"
+	case algo == EncryptionDESECB:
+		return "DES-ECB"
+	case algo == EncryptionDESCBC:
+		return "DES-CBC"
+	case algo == EncryptionTripleDES:
+		return "TripleDES"
+	case algo < 32:
+		return "Reserved"
+	case algo <= encryptionMax:
+		return "User Private"
+	}
+	return fmt.Sprintf("Invalid (%d)", uint8(algo))
+}
+
+// Valid returns false when algo does not fit in 6 bits.
+func (algo EncryptionAlgorithm) Valid() bool {
+	return algo <= encryptionMax
+}
+
 // InfoSection is the splice info section of the SCTE 35 cue.
 type InfoSection struct {
 	Name                   string
@@ -10,21 +48,21 @@ type InfoSection struct {
 	SectionLength          uint16
 	ProtocolVersion        uint8
 	EncryptedPacket        bool
-	EncryptionAlgorithm    uint8
+	EncryptionAlgorithm    EncryptionAlgorithm
 	PtsAdjustment          float64
+	PtsAdjustmentRaw       uint64 `json:",omitempty"` // PtsAdjustment in 90k ticks as on the wire, set by Decode and Encode
 	CwIndex                string
 	Tier                   string
 	CommandLength          uint16
 	CommandType            uint8
+	Stuffing               uint16 `json:",omitempty"` // alignment_stuffing bytes before the crc32
+	RawBytes               []byte `json:"-"`          // the info section bytes, set by Decode
 }
 
-// Decode Splice Info Section values.
+// Decode Splice Info Section values, the TableID is checked by the Decoder.
 func (infosec *InfoSection) Decode(bd *bitDecoder) bool {
 	infosec.Name = "Splice Info Section"
 	infosec.TableID = bd.asHex(8)
-	if infosec.TableID != "0xfc" {
-		return false
-	}
 	infosec.SectionSyntaxIndicator = bd.asFlag()
 	infosec.Private = bd.asFlag()
 	if infosec.Private {
@@ -37,8 +75,9 @@ func (infosec *InfoSection) Decode(bd *bitDecoder) bool {
 		return false
 	}
 	infosec.EncryptedPacket = bd.asFlag()
-	infosec.EncryptionAlgorithm = bd.uInt8(6)
-	infosec.PtsAdjustment = bd.as90k(33)
+	infosec.EncryptionAlgorithm = EncryptionAlgorithm(bd.uInt8(6))
+	infosec.PtsAdjustmentRaw = bd.uInt64(33)
+	infosec.PtsAdjustment = mk90k(infosec.PtsAdjustmentRaw)
 	infosec.CwIndex = bd.asHex(8)
 	infosec.Tier = bd.asHex(12)
 	infosec.CommandLength = bd.uInt16(12)
@@ -47,6 +86,11 @@ func (infosec *InfoSection) Decode(bd *bitDecoder) bool {
 	return true
 }
 
+// CWIndex returns CwIndex as a byte, the index of the control word used to encrypt the packet.
+func (infosec *InfoSection) CWIndex() byte {
+	return uint8(hexValue(infosec.CwIndex))
+}
+
 // defaults sets default InfoSection values for encoding
 func (infosec *InfoSection) defaults() {
 	infosec.Name = "Splice Info Section"
@@ -57,7 +101,7 @@ func (infosec *InfoSection) defaults() {
 	//infosec.SectionLength = 17
 	infosec.ProtocolVersion = 0
 	infosec.EncryptedPacket = false
-	infosec.EncryptionAlgorithm = 0
+	infosec.EncryptionAlgorithm = EncryptionNone
 	infosec.PtsAdjustment = 0.0
 	infosec.CwIndex = "0x0"
 	infosec.Tier = "0xfff"
@@ -72,12 +116,14 @@ Encodes the InfoSection variables to bytes.
 func (infosec *InfoSection) Encode() []byte {
 	be := &bitEncoder{}
 	be.Add(uint16(0xfc), 16)
-	be.Add(48, 8)
-	be.Add(uint8(infosec.SectionLength), 8)
+	// section syntax indicator and private are 0, reserved 0x3
+	be.Add(0x3, 4)
+	be.Add(infosec.SectionLength, 12)
 	be.Add(infosec.ProtocolVersion, 8)
 	be.Add(infosec.EncryptedPacket, 1)
-	be.Add(infosec.EncryptionAlgorithm, 6)
-	be.Add(infosec.PtsAdjustment, 33)
+	be.Add(uint8(infosec.EncryptionAlgorithm), 6)
+	infosec.PtsAdjustmentRaw = u64(infosec.PtsAdjustment) & (rollOver - 1)
+	be.Add(infosec.PtsAdjustmentRaw, 33)
 	be.AddHex64(infosec.CwIndex, 8)
 	be.AddHex64(infosec.Tier, 12)
 	be.Add(infosec.CommandLength, 12)
@@ -0,0 +1,35 @@
+package cuei_test
+
+import (
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestEncryptionFields(t *testing.T) {
+	cue := withDescriptors()
+	cue.InfoSection.EncryptedPacket = true
+	cue.InfoSection.EncryptionAlgorithm = cuei.EncryptionTripleDES
+	cue.InfoSection.CwIndex = "0x2a"
+	infosec := roundTrip(t, cue).InfoSection
+	if infosec.EncryptionAlgorithm != cuei.EncryptionTripleDES || infosec.CWIndex() != 0x2a {
+		t.Errorf("encryption algorithm %v cw index %#x", infosec.EncryptionAlgorithm, infosec.CWIndex())
+	}
+	names := map[cuei.EncryptionAlgorithm]string{
+		cuei.EncryptionNone:   "None",
+		cuei.EncryptionDESECB: "DES-ECB",
+		cuei.EncryptionDESCBC: "DES-CBC",
+		7:                     "Reserved",
+		40:                    "User Private",
+		64:                    "Invalid (64)",
+	}
+	for algo, want := range names {
+		if algo.String() != want {
+			t.Errorf("algorithm %d is %v, want %v", uint8(algo), algo, want)
+		}
+	}
+	cue.InfoSection.EncryptionAlgorithm = 64
+	if _, err := cue.MarshalBinary(); err == nil {
+		t.Error("MarshalBinary of encryption algorithm 64 did not fail")
+	}
+}
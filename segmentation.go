@@ -55,3 +55,95 @@ var table22 = map[uint8]string{
 	0x50: "Network Start",
 	0x51: "Network End",
 }
+
+/*
+segPairs maps each segmentation start type to its paired end type.
+
+	0x00 Not Indicated and 0x01 Content Identification
+	are neither starts nor ends.
+*/
+var segPairs = map[uint8]uint8{
+	0x10: 0x11,
+	0x20: 0x21,
+	0x22: 0x23,
+	0x24: 0x25,
+	0x26: 0x27,
+	0x30: 0x31,
+	0x32: 0x33,
+	0x34: 0x35,
+	0x36: 0x37,
+	0x38: 0x39,
+	0x3A: 0x3B,
+	0x3C: 0x3D,
+	0x3E: 0x3F,
+	0x40: 0x41,
+	0x42: 0x43,
+	0x44: 0x45,
+	0x46: 0x47,
+	0x50: 0x51,
+}
+
+/*
+OpLabels maps segmentation type ids to the short labels
+used by operators and ad insertion UIs, it is used by Descriptor.OpLabel.
+
+	Advertisement and placement opportunity starts are CUE-OUT,
+	their ends are CUE-IN.
+	Entries may be changed, added or deleted to match local practice,
+	do it before decoding starts, OpLabels is not safe to change concurrently.
+*/
+var OpLabels = map[uint8]string{
+	0x10: "PROGRAM",
+	0x11: "PROGRAM-END",
+	0x12: "PROGRAM-END",
+	0x13: "PROGRAM-BREAKAWAY",
+	0x14: "PROGRAM-RESUME",
+	0x17: "PROGRAM-OVERLAP",
+	0x18: "BLACKOUT-OVERRIDE",
+	0x19: "PROGRAM",
+	0x20: "CHAPTER",
+	0x21: "CHAPTER-END",
+	0x22: "BREAK",
+	0x23: "BREAK-END",
+	0x24: "CREDITS",
+	0x25: "CREDITS-END",
+	0x26: "CREDITS",
+	0x27: "CREDITS-END",
+	0x30: "CUE-OUT",
+	0x31: "CUE-IN",
+	0x32: "CUE-OUT",
+	0x33: "CUE-IN",
+	0x34: "CUE-OUT",
+	0x35: "CUE-IN",
+	0x36: "CUE-OUT",
+	0x37: "CUE-IN",
+	0x38: "OVERLAY",
+	0x39: "OVERLAY-END",
+	0x3A: "OVERLAY",
+	0x3B: "OVERLAY-END",
+	0x3C: "PROMO",
+	0x3D: "PROMO-END",
+	0x3E: "PROMO",
+	0x3F: "PROMO-END",
+	0x40: "UNSCHEDULED",
+	0x41: "UNSCHEDULED-END",
+	0x42: "ALTERNATE",
+	0x43: "ALTERNATE-END",
+	0x44: "CUE-OUT",
+	0x45: "CUE-IN",
+	0x46: "CUE-OUT",
+	0x47: "CUE-IN",
+	0x50: "NETWORK",
+	0x51: "NETWORK-END",
+}
+
+/*
+OpLabel returns the OpLabels label for the segmentation type of dscptr,
+it returns "" for other descriptors and types without a label.
+*/
+func (dscptr *Descriptor) OpLabel() string {
+	if dscptr.Tag != 0x2 {
+		return ""
+	}
+	return OpLabels[dscptr.SegmentationTypeID]
+}
@@ -0,0 +1,41 @@
+package cuei_test
+
+import (
+	"testing"
+)
+
+func TestNotIndicated(t *testing.T) {
+	dscptr := segmentation(0x00)
+	dscptr.SegmentationDurationFlag = true
+	dscptr.SegmentationDuration = 30.0
+	cue := roundTrip(t, withDescriptors(dscptr))
+	got := cue.Descriptors[0]
+	if got.SegmentationTypeID != 0x00 || got.SegmentationMessage != "Not Indicated" || got.SegmentationDuration != 30.0 {
+		t.Errorf("decoded %+v", got)
+	}
+	if _, _, ok := cue.AvailWindow(); ok {
+		t.Error("Not Indicated opens an avail window")
+	}
+	if _, err := cue.MakeReturn(100.0); err == nil {
+		t.Error("Not Indicated has a return")
+	}
+	if cue.Six2Five(); cue.Command.CommandType != 0x6 || cue.Command.SpliceEventID != 0 {
+		t.Errorf("Six2Five changed the command to %+v", cue.Command)
+	}
+}
+
+func TestSegmentationStartStop(t *testing.T) {
+	// the end is sent before the start
+	cue := roundTrip(t, withDescriptors(segmentation(0x01), segmentation(0x35), segmentation(0x34)))
+	start, stop := cue.SegmentationStart(), cue.SegmentationStop()
+	if start == nil || start.SegmentationTypeID != 0x34 {
+		t.Errorf("SegmentationStart is %+v, want type 0x34", start)
+	}
+	if stop == nil || stop.SegmentationTypeID != 0x35 {
+		t.Errorf("SegmentationStop is %+v, want type 0x35", stop)
+	}
+	cue = withDescriptors(segmentation(0x01))
+	if start, stop := cue.SegmentationStart(), cue.SegmentationStop(); start != nil || stop != nil {
+		t.Errorf("content identification start %+v stop %+v, want nil", start, stop)
+	}
+}
@@ -0,0 +1,75 @@
+package cuei
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Stats counts the Cues of a capture.
+
+	Commands is keyed by Command.CommandType,
+	SegmentationTypes by Descriptor.SegmentationTypeID.
+	CrcFailures counts Cues that fail the crc32 conformance rule.
+*/
+type Stats struct {
+	Cues              int
+	Commands          map[uint8]int
+	SegmentationTypes map[uint8]int
+	CrcFailures       int
+	names             map[uint8]string
+}
+
+// Add counts cue.
+func (stats *Stats) Add(cue *Cue) {
+	if stats.Commands == nil {
+		stats.Commands = map[uint8]int{}
+		stats.SegmentationTypes = map[uint8]int{}
+		stats.names = map[uint8]string{}
+	}
+	stats.Cues++
+	if cue.Command != nil {
+		stats.Commands[cue.Command.CommandType]++
+		stats.names[cue.Command.CommandType] = cue.Command.Name
+	}
+	cue.EachSegmentation(func(dscptr *Descriptor) {
+		stats.SegmentationTypes[dscptr.SegmentationTypeID]++
+	})
+	if level, _ := chkCrc32(cue); level == Fail {
+		stats.CrcFailures++
+	}
+}
+
+/*
+Report returns the counts as text, one per line,
+commands and segmentation types in ascending order.
+*/
+func (stats *Stats) Report() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "cues: %v\n", stats.Cues)
+	fmt.Fprintf(&sb, "crc32 failures: %v\n", stats.CrcFailures)
+	for _, k := range sortedKeys(stats.Commands) {
+		fmt.Fprintf(&sb, "command %#x %v: %v\n", k, stats.names[k], stats.Commands[k])
+	}
+	for _, k := range sortedKeys(stats.SegmentationTypes) {
+		fmt.Fprintf(&sb, "segmentation type %#x %v: %v\n", k, table22[k], stats.SegmentationTypes[k])
+	}
+	return sb.String()
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys(m map[uint8]int) []uint8 {
+	keys := make([]uint8, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// initialize and return a *Stats
+func NewStats() *Stats {
+	stats := &Stats{}
+	return stats
+}
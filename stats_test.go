@@ -0,0 +1,18 @@
+package cuei_test
+
+import (
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestStatsCrcFailures(t *testing.T) {
+	bites := withDescriptors().Encode()
+	bites[len(bites)-1] ^= 0xff
+	cue, _ := cuei.NewDecoder().Decode(bites)
+	var stats cuei.Stats
+	stats.Add(cue)
+	if stats.CrcFailures != 1 || stats.Commands[0x6] != 1 {
+		t.Errorf("stats are %+v", stats)
+	}
+}
@@ -1,16 +1,22 @@
 package cuei
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"encoding/json"
+	"io"
 	"os"
 )
 
 // packetData holds information about the packet carrying a SCTE-35
 type packetData struct {
-	Pid     uint16  `json:",omitempty"`
-	Program uint16  `json:",omitempty"`
-	Pcr     float64 `json:",omitempty"`
-	Pts     float64 `json:",omitempty"`
+	Pid       uint16   `json:",omitempty"`
+	Program   uint16   `json:",omitempty"`
+	Pcr       float64  `json:",omitempty"`
+	Pts       float64  `json:",omitempty"`
+	PacketPcr *float64 `json:",omitempty"` // PCR of the packet the section starts in, if it has one
+	Offset    int64    `json:",omitempty"` // byte offset in the stream of the packet the section starts in
 }
 
 // pktSz is the size of an MPEG-TS packet in bytes.
@@ -30,7 +36,15 @@ type Stream struct {
 	Prgm2Pts map[uint16]uint64 // program to pts map
 	last     map[uint16][]byte // last compares current packet payload to last packet payload by pid
 	partial  map[uint16][]byte // partial manages tables spread across multiple packets by pid
+	pktPcr   map[uint16]uint64 // pktPcr is the PCR of the packet starting a SCTE-35 section by pid
+	pktOff   map[uint16]int64  // pktOff is the offset of the packet starting a SCTE-35 section by pid
 	Quiet    bool              // Don't call Cue.Show() when a Cue is found.
+	// Scte35Only skips all packets not carrying PAT, PMT or SCTE-35,
+	// PacketData Pcr and Pts are not set.
+	Scte35Only bool
+	Progress   func(parsed int64) // Progress is called with the total bytes parsed after each chunk.
+	parsed     int64
+	offset     int64 // offset of the packet being parsed
 }
 
 func (stream *Stream) mkMaps() {
@@ -40,6 +54,9 @@ func (stream *Stream) mkMaps() {
 	stream.Prgm2Pts = make(map[uint16]uint64)
 	stream.last = make(map[uint16][]byte)
 	stream.partial = make(map[uint16][]byte)
+	stream.pktPcr = make(map[uint16]uint64)
+	stream.pktOff = make(map[uint16]int64)
+	stream.parsed = 0
 }
 
 // Decode fname (a file name) for SCTE-35
@@ -52,11 +69,11 @@ func (stream *Stream) Decode(fname string) []*Cue {
 	defer file.Close()
 	buffer := make([]byte, bufSz)
 	for {
-		_, err := file.Read(buffer)
+		n, err := file.Read(buffer)
 		if err != nil {
 			break
 		}
-		cues = append(cues, stream.DecodeBytes(buffer)...)
+		cues = append(cues, stream.DecodeBytes(buffer[:n])...)
 	}
 	return cues
 }
@@ -68,10 +85,16 @@ func (stream *Stream) DecodeBytes(bites []byte) []*Cue {
 		start := end - pktSz
 		p := bites[start:end]
 		pkt := &p
+		stream.offset = stream.parsed + int64(start)
 		stream.parse(*pkt)
 	}
+	stream.parsed += int64(len(bites))
+	if stream.Progress != nil {
+		stream.Progress(stream.parsed)
+	}
 	cues := stream.Cues
-	stream.Cues = stream.Cues[:0]
+	// a new slice, so the returned Cues are not overwritten by the next call
+	stream.Cues = nil
 	return cues
 }
 
@@ -80,9 +103,9 @@ func (stream *Stream) afcFlag(pkt []byte) bool {
 	return (pkt[3]&0x20 == 0x20)
 }
 
-// pcrFlag returns true if PCR flag is set
+// pcrFlag returns true if PCR flag is set in an adaptation field long enough to hold it
 func (stream *Stream) pcrFlag(pkt []byte) bool {
-	return (pkt[5]&0x10 == 0x10)
+	return pkt[4] >= 7 && (pkt[5]&0x10 == 0x10)
 }
 
 // ptsFlag returns true if PTS flag is set
@@ -113,17 +136,24 @@ func (stream *Stream) parsePts(pay []byte, pid uint16) {
 
 // parsePcr parses a packet for PCR
 func (stream *Stream) parsePcr(pkt []byte, pid uint16) {
-	if stream.afcFlag(pkt) {
-		if stream.pcrFlag(pkt) {
-			pcr := (uint64(pkt[6]) << 25)
-			pcr |= (uint64(pkt[7]) << 17)
-			pcr |= (uint64(pkt[8]) << 9)
-			pcr |= (uint64(pkt[9]) << 1)
-			pcr |= uint64(pkt[10]) >> 7
-			prgm := stream.Pid2Prgm[pid]
-			stream.Prgm2Pcr[prgm] = pcr
-		}
+	pcr, ok := stream.pcr(pkt)
+	if ok {
+		prgm := stream.Pid2Prgm[pid]
+		stream.Prgm2Pcr[prgm] = pcr
+	}
+}
+
+// pcr returns the 90k PCR base of a packet, ok is false if it has none
+func (stream *Stream) pcr(pkt []byte) (uint64, bool) {
+	if !stream.afcFlag(pkt) || !stream.pcrFlag(pkt) {
+		return 0, false
 	}
+	pcr := (uint64(pkt[6]) << 25)
+	pcr |= (uint64(pkt[7]) << 17)
+	pcr |= (uint64(pkt[8]) << 9)
+	pcr |= (uint64(pkt[9]) << 1)
+	pcr |= uint64(pkt[10]) >> 7
+	return pcr, true
 }
 
 // parsePay packet payload starts after header and afc (if present)
@@ -170,10 +200,18 @@ func (stream *Stream) sectionDone(pay []byte, pid uint16, seclen uint16) bool {
 	return true
 }
 
+// wanted returns true if pid carries PAT, PMT or SCTE-35.
+func (stream *Stream) wanted(pid uint16) bool {
+	return pid == 0 || stream.Pids.isPmtPid(pid) || stream.Pids.isScte35Pid(pid)
+}
+
 // parse is the parser method for Stream
 func (stream *Stream) parse(pkt []byte) {
 	p := parsePid(pkt[1], pkt[2])
 	pid := &p
+	if stream.Scte35Only && !stream.wanted(*pid) {
+		return
+	}
 	pl := stream.parsePayload(pkt)
 	pay := &pl
 	if *pid == 0 {
@@ -189,10 +227,24 @@ func (stream *Stream) parse(pkt []byte) {
 		stream.parsePts(*pay, *pid)
 	}
 	if stream.Pids.isScte35Pid(*pid) {
+		if stream.parsePusi(pkt) {
+			stream.parsePktPcr(pkt, *pid)
+		}
 		stream.parseScte35(*pay, *pid)
 	}
 }
 
+// parsePktPcr keeps the PCR and offset of a packet starting a SCTE-35 section by pid
+func (stream *Stream) parsePktPcr(pkt []byte, pid uint16) {
+	stream.pktOff[pid] = stream.offset
+	pcr, ok := stream.pcr(pkt)
+	if ok {
+		stream.pktPcr[pid] = pcr
+		return
+	}
+	delete(stream.pktPcr, pid)
+}
+
 // parsePat parses PAT payload
 func (stream *Stream) parsePat(pay []byte, pid uint16) {
 	if stream.sameAsLast(pay, pid) {
@@ -254,20 +306,45 @@ func (stream *Stream) parseStreams(silen uint16, pay []byte, idx uint16, prgm ui
 		elpid := parsePid(pay[idx+1], pay[idx+2])
 		eilen := parseLen(pay[idx+3], pay[idx+4])
 		idx += chunksize
+		var esinfo []byte
+		if int(idx+eilen) <= len(pay) {
+			esinfo = pay[idx : idx+eilen]
+		}
 		idx += eilen
 		stream.Pid2Prgm[elpid] = prgm
 		stream.Pid2Type[elpid] = streamtype
-		stream.vrfyStreamType(elpid, streamtype)
+		stream.vrfyStreamType(elpid, streamtype, registeredCUEI(esinfo))
 	}
 }
 
-// vrfyStreamType checks for stream types 6 and 134 and adds them to Stream.Pids.Scte35Pids
-func (stream *Stream) vrfyStreamType(pid uint16, streamtype uint8) {
-	if streamtype == 6 || streamtype == 134 {
+/*
+vrfyStreamType checks for stream types 6 and 134 and adds them to Stream.Pids.Scte35Pids,
+a stream of any type with a CUEI registration_descriptor is added too.
+*/
+func (stream *Stream) vrfyStreamType(pid uint16, streamtype uint8, registered bool) {
+	if streamtype == 6 || streamtype == 134 || registered {
 		stream.Pids.addScte35Pid(pid)
 	}
 }
 
+// registeredCUEI returns true if esinfo has a registration_descriptor, tag 0x05, with the format_identifier CUEI.
+func registeredCUEI(esinfo []byte) bool {
+	for len(esinfo) >= 2 {
+		tag, length := esinfo[0], int(esinfo[1])
+		if 2+length > len(esinfo) {
+			return false
+		}
+		if tag == 0x05 && length >= 4 {
+			id := uint32(esinfo[2])<<24 | uint32(esinfo[3])<<16 | uint32(esinfo[4])<<8 | uint32(esinfo[5])
+			if id == cueIdentifier {
+				return true
+			}
+		}
+		esinfo = esinfo[2+length:]
+	}
+	return false
+}
+
 // parseSCTE35 parses SCTE35 packets
 func (stream *Stream) parseScte35(pay []byte, pid uint16) {
 	pay = stream.chkPartial(pay, pid, []byte("\xfc0"))
@@ -299,9 +376,108 @@ func (stream *Stream) mkCue(pid uint16) *Cue {
 	cue.PacketData.Program = *prgm
 	cue.PacketData.Pcr = mk90k(stream.Prgm2Pcr[*prgm])
 	cue.PacketData.Pts = mk90k(stream.Prgm2Pts[*prgm])
+	if pcr, ok := stream.pktPcr[pid]; ok {
+		pktPcr := mk90k(pcr)
+		cue.PacketData.PacketPcr = &pktPcr
+	}
+	cue.PacketData.Offset = stream.pktOff[pid]
 	return cue
 }
 
+// ParseTS is ParseTSContext with context.Background().
+func ParseTS(r io.Reader, pids PIDFilter) <-chan *Cue {
+	return ParseTSContext(context.Background(), r, pids)
+}
+
+/*
+ParseTSContext reads MPEGTS from r and sends the SCTE-35 Cues found
+on pids passing the filter.
+
+	The returned channel is closed when r is exhausted or ctx is done,
+	the reading goroutine then exits.
+	Each r.Read is parsed as it returns, partial packets are kept
+	for the next read, so Cues from a live source are sent as they arrive.
+	ctx is checked between reads, a blocked r.Read is not interrupted,
+	so a cancel takes effect when it returns.
+*/
+func ParseTSContext(ctx context.Context, r io.Reader, pids PIDFilter) <-chan *Cue {
+	cues := make(chan *Cue)
+	go func() {
+		defer close(cues)
+		stream := NewStream()
+		stream.Quiet = true
+		buffer := make([]byte, bufSz)
+		have := 0
+		for ctx.Err() == nil {
+			n, err := r.Read(buffer[have:])
+			have += n
+			whole := have - have%pktSz
+			for _, cue := range stream.DecodeBytes(buffer[:whole]) {
+				if !pids.allows(cue.PacketData.Pid) {
+					continue
+				}
+				select {
+				case cues <- cue:
+				case <-ctx.Done():
+					return
+				}
+			}
+			have = copy(buffer, buffer[whole:have])
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return cues
+}
+
+/*
+ParseTSToJSON reads MPEGTS from r and writes each SCTE-35 Cue
+on pids to w as one line of compact JSON, as it is found.
+
+	PacketData has the PCR and the byte offset of the packet the Cue starts in,
+	an empty pids writes Cues on every SCTE-35 pid.
+	w is flushed after each read that found a Cue,
+	so a live stream is written as it arrives.
+	The error is nil when r is exhausted.
+*/
+func ParseTSToJSON(r io.Reader, w io.Writer, pids []uint16) error {
+	filter := PIDFilter(pids)
+	stream := NewStream()
+	stream.Quiet = true
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	buffer := make([]byte, bufSz)
+	have := 0
+	for {
+		n, err := r.Read(buffer[have:])
+		have += n
+		whole := have - have%pktSz
+		found := false
+		for _, cue := range stream.DecodeBytes(buffer[:whole]) {
+			if !filter.allows(cue.PacketData.Pid) {
+				continue
+			}
+			found = true
+			if err := enc.Encode(cue); err != nil {
+				return err
+			}
+		}
+		have = copy(buffer, buffer[whole:have])
+		if found {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return bw.Flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
 // initialize and return a *Stream
 func NewStream() *Stream {
 	stream := &Stream{}
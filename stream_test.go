@@ -0,0 +1,581 @@
+package cuei_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"runtime"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/futzu/cuei"
+)
+
+const (
+	testPmtPid    = 0x100
+	testScte35Pid = 0x1f0
+	testData      = "/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo="
+)
+
+// tsPacket returns a 188 byte packet carrying a section on pid.
+func tsPacket(pid uint16, section []byte) []byte {
+	pkt := bytes.Repeat([]byte{0xff}, 188)
+	pkt[0] = 0x47
+	pkt[1] = 0x40 | byte(pid>>8)
+	pkt[2] = byte(pid)
+	pkt[3] = 0x10
+	pkt[4] = 0x00 // pointer field
+	copy(pkt[5:], section)
+	return pkt
+}
+
+// patPacket maps program 1 to testPmtPid.
+func patPacket() []byte {
+	return tsPacket(0, []byte{
+		0x00, 0xb0, 0x0d, 0x00, 0x01, 0xc1, 0x00, 0x00,
+		0x00, 0x01, 0xe0 | testPmtPid>>8, testPmtPid & 0xff,
+		0x00, 0x00, 0x00, 0x00,
+	})
+}
+
+// pmtPacket signals testScte35Pid as a stream of streamType.
+func pmtPacket(streamType byte) []byte {
+	return tsPacket(testPmtPid, []byte{
+		0x02, 0xb0, 0x12, 0x00, 0x01, 0xc1, 0x00, 0x00,
+		0xe1, 0x01, 0xf0, 0x00,
+		streamType, 0xe0 | testScte35Pid>>8, testScte35Pid & 0xff, 0xf0, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+	})
+}
+
+// registeredPmtPacket signals testScte35Pid as a stream of streamType with a registration_descriptor for format.
+func registeredPmtPacket(streamType byte, format string) []byte {
+	return tsPacket(testPmtPid, append([]byte{
+		0x02, 0xb0, 0x18, 0x00, 0x01, 0xc1, 0x00, 0x00,
+		0xe1, 0x01, 0xf0, 0x00,
+		streamType, 0xe0 | testScte35Pid>>8, testScte35Pid & 0xff, 0xf0, 0x06,
+		0x05, 0x04}, append([]byte(format), 0x00, 0x00, 0x00, 0x00)...))
+}
+
+// tsStream returns a PAT, a PMT and a SCTE-35 packet carrying testData.
+func tsStream() []byte {
+	section, _ := base64.StdEncoding.DecodeString(testData)
+	var ts []byte
+	ts = append(ts, patPacket()...)
+	ts = append(ts, pmtPacket(0x86)...)
+	ts = append(ts, tsPacket(testScte35Pid, section)...)
+	return ts
+}
+
+// videoPacket is a packet on pid 0x101 with a PCR and a PES header with a PTS.
+func videoPacket() []byte {
+	pkt := bytes.Repeat([]byte{0xff}, 188)
+	copy(pkt, []byte{
+		0x47, 0x41, 0x01, 0x30, 0x07, 0x10, 0x00, 0x00, 0x7e, 0x90, 0x7e, 0x00,
+		0x00, 0x00, 0x01, 0xe0, 0x00, 0x00, 0x80, 0x80, 0x05, 0x21, 0x00, 0x07, 0xd8, 0x61,
+	})
+	return pkt
+}
+
+// largeStream is tsStream followed by n video packets.
+func largeStream(n int) []byte {
+	ts := tsStream()
+	ts = append(ts[:376:376], bytes.Repeat(videoPacket(), n)...)
+	return append(ts, tsStream()[376:]...)
+}
+
+func TestStreamScte35Only(t *testing.T) {
+	ts := largeStream(1000)
+	var parsed int64
+	stream := cuei.NewStream()
+	stream.Quiet = true
+	stream.Scte35Only = true
+	stream.Progress = func(n int64) { parsed = n }
+	cues := stream.DecodeBytes(ts)
+	if len(cues) != 1 || cues[0].Encode2B64() != testData {
+		t.Fatalf("found %v cues", len(cues))
+	}
+	if parsed != int64(len(ts)) {
+		t.Errorf("progress is %v bytes, want %v", parsed, len(ts))
+	}
+	if cues[0].PacketData.Pts != 0.0 {
+		t.Errorf("Pts is %v, want 0 with Scte35Only", cues[0].PacketData.Pts)
+	}
+}
+
+func TestPacketPcr(t *testing.T) {
+	ts := tsStream()
+	stream := cuei.NewStream()
+	stream.Quiet = true
+	cues := stream.DecodeBytes(ts)
+	if len(cues) != 1 || cues[0].PacketData.PacketPcr != nil {
+		t.Fatalf("PacketPcr is set without an adaptation field")
+	}
+	// move the section behind an adaptation field with a 0.72 second PCR.
+	pkt := ts[376:]
+	copy(pkt[12:], append([]byte(nil), pkt[4:180]...))
+	pkt[3] = 0x30
+	copy(pkt[4:], []byte{0x07, 0x10, 0x00, 0x00, 0x7e, 0x90, 0x7e, 0x00})
+	stream = cuei.NewStream()
+	stream.Quiet = true
+	cues = stream.DecodeBytes(ts)
+	if len(cues) != 1 || cues[0].PacketData.PacketPcr == nil {
+		t.Fatal("PacketPcr is not set")
+	}
+	if pcr := *cues[0].PacketData.PacketPcr; pcr != 0.72 {
+		t.Errorf("PacketPcr is %v, want 0.72", pcr)
+	}
+}
+
+// rtpPacket wraps a TS packet in an RTP header with sequence number seq.
+func rtpPacket(seq uint16, ts []byte) []byte {
+	pkt := []byte{0x80, 33, byte(seq >> 8), byte(seq), 0, 0, 0, 0, 0, 0, 0, 1}
+	return append(pkt, ts...)
+}
+
+func TestRTPDepacketizer(t *testing.T) {
+	ts := tsStream()
+	rtp := cuei.NewRTPDepacketizer()
+	var warnings []string
+	rtp.OnWarning = func(warning string) { warnings = append(warnings, warning) }
+	var cues []*cuei.Cue
+	for i, seq := range []uint16{0xfffe, 0xffff, 1} {
+		found, err := rtp.Decode(rtpPacket(seq, ts[i*188:(i+1)*188]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cues = append(cues, found...)
+	}
+	if len(cues) != 1 || cues[0].Encode2B64() != testData {
+		t.Errorf("found %v cues", len(cues))
+	}
+	want := "rtp sequence gap, 1 packets lost after 65535"
+	if rtp.Gaps != 1 || len(warnings) != 1 || warnings[0] != want {
+		t.Errorf("gaps %v warnings %v", rtp.Gaps, warnings)
+	}
+	if _, err := rtp.Decode([]byte{0x40, 0, 0}); err == nil {
+		t.Error("a short rtp packet was decoded")
+	}
+}
+
+func TestRTPLatePackets(t *testing.T) {
+	ts := tsStream()
+	rtp := cuei.NewRTPDepacketizer()
+	var warnings []string
+	rtp.OnWarning = func(warning string) { warnings = append(warnings, warning) }
+	var cues []*cuei.Cue
+	// the second 0xffff is a duplicate, 0xfffd is late, 0 follows 0xffff
+	for _, pkt := range []struct {
+		seq uint16
+		ts  int
+	}{{0xfffe, 0}, {0xffff, 1}, {0xffff, 1}, {0xfffd, 0}, {0, 2}} {
+		found, err := rtp.Decode(rtpPacket(pkt.seq, ts[pkt.ts*188:(pkt.ts+1)*188]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cues = append(cues, found...)
+	}
+	if len(cues) != 1 || rtp.Gaps != 0 || rtp.Late != 2 {
+		t.Errorf("found %v cues, gaps %v, late %v", len(cues), rtp.Gaps, rtp.Late)
+	}
+	want := "rtp packet 65535 is a duplicate or late after 65535, dropped"
+	if len(warnings) != 2 || warnings[0] != want {
+		t.Errorf("warnings %v", warnings)
+	}
+}
+
+func benchmarkStream(b *testing.B, scte35Only bool) {
+	ts := largeStream(10000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(ts)))
+	for i := 0; i < b.N; i++ {
+		stream := cuei.NewStream()
+		stream.Quiet = true
+		stream.Scte35Only = scte35Only
+		stream.DecodeBytes(ts)
+	}
+}
+
+func BenchmarkStreamDecodeBytes(b *testing.B) {
+	benchmarkStream(b, false)
+}
+
+func BenchmarkStreamScte35Only(b *testing.B) {
+	benchmarkStream(b, true)
+}
+
+// pcapHeader is a little endian pcap global header for Ethernet.
+func pcapHeader() []byte {
+	head := make([]byte, 24)
+	binary.LittleEndian.PutUint32(head, 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(head[4:], 2)
+	binary.LittleEndian.PutUint16(head[6:], 4)
+	binary.LittleEndian.PutUint32(head[16:], 65535)
+	binary.LittleEndian.PutUint32(head[20:], 1)
+	return head
+}
+
+// pcapRecord wraps dgram in a record of an Ethernet, IPv4 with ihl and UDP to port frame.
+func pcapRecord(port uint16, ihl byte, dgram []byte) []byte {
+	frame := make([]byte, 14+20+8)
+	binary.BigEndian.PutUint16(frame[12:], 0x0800)
+	ip := frame[14:]
+	ip[0] = 0x40 | ihl
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+8+len(dgram)))
+	ip[8] = 64
+	ip[9] = 17
+	udp := ip[20:]
+	binary.BigEndian.PutUint16(udp[0:], 5000)
+	binary.BigEndian.PutUint16(udp[2:], port)
+	binary.BigEndian.PutUint16(udp[4:], uint16(8+len(dgram)))
+	frame = append(frame, dgram...)
+	rec := make([]byte, 16)
+	binary.LittleEndian.PutUint32(rec[8:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(rec[12:], uint32(len(frame)))
+	return append(rec, frame...)
+}
+
+// pcapCapture wraps each dgram in an Ethernet, IPv4 and UDP frame.
+func pcapCapture(dgrams ...[]byte) []byte {
+	capture := pcapHeader()
+	for _, dgram := range dgrams {
+		capture = append(capture, pcapRecord(5000, 5, dgram)...)
+	}
+	return capture
+}
+
+func TestParsePCAP(t *testing.T) {
+	ts := tsStream()
+	capture := pcapCapture(ts[:188], ts[188:])
+
+	cues, err := cuei.ParsePCAP(bytes.NewReader(capture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []*cuei.Cue
+	for cue := range cues {
+		found = append(found, cue)
+	}
+	if len(found) != 1 {
+		t.Fatalf("found %v cues, want 1", len(found))
+	}
+	if found[0].PacketData.Pid != testScte35Pid || found[0].Encode2B64() != testData {
+		t.Errorf("cue on pid %#x is %v", found[0].PacketData.Pid, found[0].Encode2B64())
+	}
+
+	cues, _ = cuei.ParsePCAP(bytes.NewReader(capture), cuei.PIDFilter{0x1f1})
+	for cue := range cues {
+		t.Errorf("filtered pid %#x was not dropped", cue.PacketData.Pid)
+	}
+
+	_, err = cuei.ParsePCAP(bytes.NewReader(ts), nil)
+	if err == nil {
+		t.Error("MPEGTS was parsed as pcap")
+	}
+}
+
+// endless repeats ts forever.
+type endless struct {
+	ts  []byte
+	idx int
+}
+
+func (e *endless) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], e.ts[e.idx:])
+		n += c
+		e.idx = (e.idx + c) % len(e.ts)
+	}
+	return n, nil
+}
+
+func TestParseTS(t *testing.T) {
+	var found int
+	for cue := range cuei.ParseTS(bytes.NewReader(tsStream()), cuei.PIDFilter{testScte35Pid}) {
+		if cue.Encode2B64() != testData {
+			t.Errorf("cue is %v", cue.Encode2B64())
+		}
+		found++
+	}
+	if found != 1 {
+		t.Errorf("found %v cues, want 1", found)
+	}
+}
+
+func TestParseTSContextCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	cues := cuei.ParseTSContext(ctx, &endless{ts: tsStream()}, nil)
+	<-cues
+	cancel()
+	for range cues {
+	}
+	// the channel is closed, wait for the goroutine to exit.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if runtime.NumGoroutine() > before {
+		t.Errorf("%v goroutines running, want %v", runtime.NumGoroutine(), before)
+	}
+}
+
+// emsgBox returns a version 0 or 1 emsg box carrying testData.
+func emsgBox(version byte) []byte {
+	data, _ := base64.StdEncoding.DecodeString(testData)
+	strs := []byte("urn:scte:scte35:2013:bin\x00\x00")
+	body := []byte{version, 0, 0, 0}
+	timing := []byte{0, 0x1, 0x5f, 0x90} // timescale 90000
+	if version == 0 {
+		body = append(body, strs...)
+		body = append(body, timing...)
+		body = append(body, 0, 0, 0x1, 0x2c) // presentation_time_delta
+	} else {
+		body = append(body, timing...)
+		body = append(body, 0, 0, 0, 0, 0, 0, 0x1, 0x2c) // presentation_time
+	}
+	body = append(body, 0, 0x52, 0xcc, 0xf5) // event_duration
+	body = append(body, 0, 0, 0, 0x7)        // id
+	if version == 1 {
+		body = append(body, strs...)
+	}
+	body = append(body, data...)
+	box := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(box, uint32(8+len(body)))
+	copy(box[4:], "emsg")
+	return append(box, body...)
+}
+
+func TestFromEMSG(t *testing.T) {
+	for _, version := range []byte{0, 1} {
+		cue, timings, err := cuei.FromEMSG(emsgBox(version))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cue.Encode2B64() != testData || len(timings) != 1 {
+			t.Fatalf("version %v cue %v with %v timings", version, cue.Encode2B64(), len(timings))
+		}
+		timing := timings[0]
+		if timing.Timescale != 90000 || timing.EventDuration != 0x52ccf5 || timing.ID != 7 {
+			t.Errorf("version %v timing %+v", version, timing)
+		}
+		if timing.PresentationTime+uint64(timing.PresentationTimeDelta) != 300 {
+			t.Errorf("version %v presentation time %+v", version, timing)
+		}
+	}
+	_, _, err := cuei.FromEMSG(emsgBox(2))
+	if err == nil {
+		t.Error("emsg version 2 was decoded")
+	}
+}
+
+func TestParseTSToJSON(t *testing.T) {
+	ts := append(largeStream(3), largeStream(3)[376:]...)
+	// move each section behind an adaptation field with a 0.72 second PCR.
+	for _, off := range []int{5 * 188, 9 * 188} {
+		pkt := ts[off : off+188]
+		copy(pkt[12:], append([]byte(nil), pkt[4:180]...))
+		pkt[3] = 0x30
+		copy(pkt[4:], []byte{0x07, 0x10, 0x00, 0x00, 0x7e, 0x90, 0x7e, 0x00})
+	}
+	var out bytes.Buffer
+	// one byte a read splits every packet across reads
+	if err := cuei.ParseTSToJSON(iotest.OneByteReader(bytes.NewReader(ts)), &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("wrote %v lines, want 2:\n%s", len(lines), out.Bytes())
+	}
+	for i, want := range []int64{5 * 188, 9 * 188} {
+		var cue cuei.Cue
+		if err := json.Unmarshal(lines[i], &cue); err != nil {
+			t.Fatal(err)
+		}
+		pd := cue.PacketData
+		if pd.Offset != want || pd.PacketPcr == nil || *pd.PacketPcr != 0.72 || pd.Pid != testScte35Pid {
+			t.Errorf("cue %v packet data is %+v, want offset %v", i, cue.PacketData, want)
+		}
+	}
+	out.Reset()
+	if err := cuei.ParseTSToJSON(bytes.NewReader(ts), &out, []uint16{0x1f1}); err != nil || out.Len() != 0 {
+		t.Errorf("filtered pid wrote %q, err %v", out.Bytes(), err)
+	}
+}
+
+func TestRegistrationDescriptor(t *testing.T) {
+	section, _ := base64.StdEncoding.DecodeString(testData)
+	for _, test := range []struct {
+		format string
+		want   int
+	}{
+		{"CUEI", 1},
+		{"KLVA", 0},
+	} {
+		ts := append(patPacket(), registeredPmtPacket(0xc0, test.format)...)
+		ts = append(ts, tsPacket(testScte35Pid, section)...)
+		stream := cuei.NewStream()
+		stream.Quiet = true
+		cues := stream.DecodeBytes(ts)
+		if len(cues) != test.want || len(stream.Pids.Scte35Pids) != test.want {
+			t.Errorf("%v registration found %v cues on pids %v", test.format, len(cues), stream.Pids.Scte35Pids)
+		}
+	}
+}
+
+func TestRTPDecodeKeepsCues(t *testing.T) {
+	ts := tsStream()
+	second, _ := base64.StdEncoding.DecodeString("/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==")
+	rtp := cuei.NewRTPDepacketizer()
+	for i := 0; i < 2; i++ {
+		if _, err := rtp.Decode(rtpPacket(uint16(i), ts[i*188:(i+1)*188])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	first, err := rtp.Decode(rtpPacket(2, ts[376:]))
+	if err != nil || len(first) != 1 {
+		t.Fatalf("first decode found %v cues: %v", len(first), err)
+	}
+	next, err := rtp.Decode(rtpPacket(3, tsPacket(testScte35Pid, second)))
+	if err != nil || len(next) != 1 {
+		t.Fatalf("second decode found %v cues: %v", len(next), err)
+	}
+	if got := first[0].Encode2B64(); got != testData {
+		t.Errorf("first cue changed to %v after the second decode", got)
+	}
+}
+
+func TestParseTSContextLive(t *testing.T) {
+	pr, pw := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cues := cuei.ParseTSContext(ctx, pr, nil)
+	go func() {
+		// writes that split packets, then the source stays open
+		ts := tsStream()
+		for len(ts) > 0 {
+			n := 100
+			if n > len(ts) {
+				n = len(ts)
+			}
+			pw.Write(ts[:n])
+			ts = ts[n:]
+		}
+	}()
+	select {
+	case cue := <-cues:
+		if cue.Encode2B64() != testData {
+			t.Errorf("live cue is %v", cue.Encode2B64())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the cue was held back by an open source")
+	}
+	cancel()
+	pw.Close()
+	for range cues {
+	}
+}
+
+// pcapCues returns the Cues and read error of ParsePCAPContext for capture.
+func pcapCues(t *testing.T, capture []byte) ([]*cuei.Cue, error) {
+	t.Helper()
+	cues, readErr, err := cuei.ParsePCAPContext(context.Background(), bytes.NewReader(capture), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []*cuei.Cue
+	for cue := range cues {
+		found = append(found, cue)
+	}
+	return found, readErr()
+}
+
+func TestParsePCAPRecords(t *testing.T) {
+	ts := tsStream()
+	if found, err := pcapCues(t, pcapCapture(ts[:188], ts[188:])); len(found) != 1 || err != nil {
+		t.Errorf("found %v cues: %v", len(found), err)
+	}
+	// incl_len past the snaplen
+	huge := append(pcapCapture(ts), pcapRecord(5000, 5, ts)...)
+	binary.LittleEndian.PutUint32(huge[len(huge)-len(ts)-42-8:], 0xffffffff)
+	if found, err := pcapCues(t, huge); len(found) != 1 || err == nil {
+		t.Errorf("huge record found %v cues: %v", len(found), err)
+	}
+	truncated := pcapCapture(ts)
+	if _, err := pcapCues(t, truncated[:len(truncated)-10]); err == nil {
+		t.Error("truncated capture has no read error")
+	}
+	// an ihl of 4 words is too short for an IPv4 header
+	bad := append(pcapHeader(), pcapRecord(5000, 4, ts)...)
+	if found, _ := pcapCues(t, bad); len(found) != 0 {
+		t.Errorf("ihl 4 found %v cues", len(found))
+	}
+}
+
+// withTrailer appends trailer to the frame of a pcapRecord, like a captured fcs.
+func withTrailer(rec []byte, trailer ...byte) []byte {
+	rec = append(append([]byte(nil), rec...), trailer...)
+	n := uint32(len(rec) - 16)
+	binary.LittleEndian.PutUint32(rec[8:], n)
+	binary.LittleEndian.PutUint32(rec[12:], n)
+	return rec
+}
+
+func TestParsePCAPTrailer(t *testing.T) {
+	ts := tsStream()
+	capture := pcapHeader()
+	for _, dgram := range [][]byte{ts[:188], ts[188:]} {
+		capture = append(capture, withTrailer(pcapRecord(5000, 5, dgram), 0xde, 0xad, 0xbe, 0xef)...)
+	}
+	if found, err := pcapCues(t, capture); len(found) != 1 || err != nil {
+		t.Errorf("found %v cues: %v", len(found), err)
+	}
+	// a udp_length past the frame is skipped
+	short := pcapRecord(5000, 5, ts)
+	binary.BigEndian.PutUint16(short[16+14+20+4:], uint16(8+len(ts)+1))
+	if found, _ := pcapCues(t, append(pcapHeader(), short...)); len(found) != 0 {
+		t.Errorf("udp length past the frame found %v cues", len(found))
+	}
+}
+
+func TestParsePCAPFlows(t *testing.T) {
+	ts := tsStream()
+	second, _ := base64.StdEncoding.DecodeString("/DAWAAAAAAAAAP/wBQb+AKmKxwAACzuu2Q==")
+	// port 5002 carries a cue on the scte35 pid of port 5000 but no PAT or PMT.
+	capture := append(pcapHeader(), pcapRecord(5000, 5, ts[:376])...)
+	capture = append(capture, pcapRecord(5002, 5, tsPacket(testScte35Pid, second))...)
+	capture = append(capture, pcapRecord(5000, 5, ts[376:])...)
+	found, err := pcapCues(t, capture)
+	if err != nil || len(found) != 1 || found[0].Encode2B64() != testData {
+		t.Errorf("found %v cues: %v", len(found), err)
+	}
+}
+
+func TestParsePCAPContextCancel(t *testing.T) {
+	before := runtime.NumGoroutine()
+	ctx, cancel := context.WithCancel(context.Background())
+	r := io.MultiReader(bytes.NewReader(pcapHeader()), &endless{ts: pcapRecord(5000, 5, tsStream())})
+	cues, readErr, err := cuei.ParsePCAPContext(ctx, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-cues
+	cancel()
+	for range cues {
+	}
+	if err := readErr(); err != nil {
+		t.Errorf("read error after a cancel: %v", err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if runtime.NumGoroutine() > before {
+		t.Errorf("%v goroutines running, want %v", runtime.NumGoroutine(), before)
+	}
+}
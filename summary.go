@@ -0,0 +1,431 @@
+package cuei
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONOptions are the options of Cue.JsonWith.
+type JSONOptions struct {
+	Summaries bool // add a derived Summary to the Command and each Segmentation Descriptor
+}
+
+/*
+JsonWith returns cue as indented JSON like Show, with opts.
+
+	The JSON of Encode and json.Marshal has no Summary,
+	JsonWith with Summaries set adds one per Command and Descriptor.
+*/
+func (cue *Cue) JsonWith(opts JSONOptions) string {
+	out := cueJSON{
+		InfoSection: cue.InfoSection,
+		Dll:         cue.Dll,
+		PacketData:  cue.PacketData,
+		Crc32:       cue.Crc32,
+		Warnings:    cue.Warnings,
+		Partial:     cue.Partial,
+		Ciphertext:  cue.Ciphertext,
+	}
+	if cue.Command != nil {
+		out.Command = &summarized{cmd: cue.Command, summary: opts.Summaries}
+	}
+	for i := range cue.Descriptors {
+		out.Descriptors = append(out.Descriptors, summarized{dscptr: &cue.Descriptors[i], summary: opts.Summaries})
+	}
+	return mkJson(out)
+}
+
+// cueJSON has the fields of Cue, in order, with the Command and Descriptors summarized.
+type cueJSON struct {
+	InfoSection *InfoSection
+	Command     *summarized
+	Dll         uint16       `json:"DescriptorLoopLength"`
+	Descriptors []summarized `json:",omitempty"`
+	PacketData  *packetData  `json:",omitempty"`
+	Crc32       uint32
+	Warnings    []string `json:",omitempty"`
+	Partial     bool     `json:",omitempty"`
+	Ciphertext  bool     `json:",omitempty"`
+}
+
+// summarized marshals a Command or Descriptor with its Summary when summary is true.
+type summarized struct {
+	cmd     *Command
+	dscptr  *Descriptor
+	summary bool
+}
+
+func (sz summarized) MarshalJSON() ([]byte, error) {
+	if sz.cmd != nil {
+		return sz.cmd.marshalJSON(sz.summary)
+	}
+	return sz.dscptr.marshalJSON(sz.summary)
+}
+
+// Summary returns a human readable summary of the Splice Command.
+func (cmd *Command) Summary() string {
+	parts := []string{cmd.Name}
+	switch cmd.CommandType {
+	case 0x5:
+		parts = append(parts, fmt.Sprintf("event %v", cmd.SpliceEventID))
+		if cmd.SpliceEventCancelIndicator {
+			return strings.Join(append(parts, "cancel"), ", ")
+		}
+		if cmd.OutOfNetworkIndicator {
+			parts = append(parts, "out")
+		} else {
+			parts = append(parts, "in")
+		}
+		if cmd.DurationFlag {
+			parts = append(parts, fmt.Sprintf("duration %vs", cmd.BreakDuration))
+			if cmd.BreakAutoReturn {
+				parts = append(parts, "auto return")
+			}
+		}
+		if cmd.SpliceImmediateFlag {
+			parts = append(parts, "immediate")
+		} else if cmd.TimeSpecifiedFlag {
+			parts = append(parts, fmt.Sprintf("pts %v", cmd.PTS))
+		}
+	case 0x6:
+		if cmd.TimeSpecifiedFlag {
+			parts = append(parts, fmt.Sprintf("pts %v", cmd.PTS))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Summary returns a human readable summary of a Segmentation Descriptor.
+func (dscptr *Descriptor) Summary() string {
+	if dscptr.Tag != 0x2 {
+		return ""
+	}
+	mesg, ok := table22[dscptr.SegmentationTypeID]
+	if !ok {
+		mesg = fmt.Sprintf("Segmentation Type %#x", dscptr.SegmentationTypeID)
+	}
+	parts := []string{mesg, fmt.Sprintf("event %v", dscptr.SegmentationEventID)}
+	if dscptr.SegmentationEventCancelIndicator {
+		return strings.Join(append(parts, "cancel"), ", ")
+	}
+	if dscptr.HasDuration() {
+		parts = append(parts, fmt.Sprintf("duration %vs", dscptr.SegmentationDuration))
+	}
+	if upid, _ := dscptr.ParseUPID(); upid != nil {
+		parts = append(parts, fmt.Sprintf("UPID(%v)=%v", upid.Name, upid.Value))
+	}
+	return strings.Join(parts, ", ")
+}
+
+/*
+hexID renders an id field of nbits as lower case hex,
+zero padded to the width of the field, like 0x0000162a.
+*/
+func hexID(id uint64, nbits int) string {
+	return fmt.Sprintf("0x%0*x", nbits/4, id)
+}
+
+/*
+MarshalJSON adds SpliceEventIDHex and UniqueProgramIDHex to a Splice Insert,
+the ids rendered by hexID.
+*/
+func (cmd Command) MarshalJSON() ([]byte, error) {
+	return cmd.marshalJSON(false)
+}
+
+// marshalJSON is MarshalJSON, with the Summary when summary is true.
+func (cmd Command) marshalJSON(summary bool) ([]byte, error) {
+	type command Command // command has no MarshalJSON method
+	out := struct {
+		command
+		SpliceEventIDHex   string `json:",omitempty"`
+		UniqueProgramIDHex string `json:",omitempty"`
+		Summary            string `json:",omitempty"`
+	}{command: command(cmd)}
+	if cmd.CommandType == 0x5 {
+		out.SpliceEventIDHex = hexID(uint64(cmd.SpliceEventID), 32)
+		if !cmd.SpliceEventCancelIndicator {
+			out.UniqueProgramIDHex = hexID(uint64(cmd.UniqueProgramID), 16)
+		}
+	}
+	if summary {
+		out.Summary = cmd.Summary()
+	}
+	return json.Marshal(out)
+}
+
+// MarshalJSON adds SpliceEventIDHex and UniqueProgramIDHex, the ids rendered by hexID.
+func (evt SpliceEvent) MarshalJSON() ([]byte, error) {
+	type spliceEvent SpliceEvent // spliceEvent has no MarshalJSON method
+	out := struct {
+		spliceEvent
+		SpliceEventIDHex   string
+		UniqueProgramIDHex string `json:",omitempty"`
+	}{spliceEvent: spliceEvent(evt), SpliceEventIDHex: hexID(uint64(evt.SpliceEventID), 32)}
+	if !evt.SpliceEventCancelIndicator {
+		out.UniqueProgramIDHex = hexID(uint64(evt.UniqueProgramID), 16)
+	}
+	return json.Marshal(out)
+}
+
+/*
+MarshalJSON renders SegmentationEventID by hexID,
+an Avail Descriptor also gets ProviderAvailIDHex.
+
+	Identifier is the 4 char form, like "CUEI",
+	or hexID when it is not printable.
+*/
+func (dscptr Descriptor) MarshalJSON() ([]byte, error) {
+	return dscptr.marshalJSON(false)
+}
+
+// marshalJSON is MarshalJSON, with the Summary when summary is true.
+func (dscptr Descriptor) marshalJSON(summary bool) ([]byte, error) {
+	type descriptor Descriptor // descriptor has no MarshalJSON method
+	out := struct {
+		descriptor
+		Identifier         string `json:",omitempty"`
+		ProviderAvailIDHex string `json:",omitempty"`
+		Summary            string `json:",omitempty"`
+	}{descriptor: descriptor(dscptr)}
+	if dscptr.Identifier != 0 {
+		out.Identifier = identifierString(dscptr.Identifier)
+	}
+	if dscptr.SegmentationEventID != "" {
+		out.SegmentationEventID = hexID(uint64(hexValue(dscptr.SegmentationEventID)), 32)
+	}
+	if dscptr.Tag == 0x0 {
+		out.ProviderAvailIDHex = hexID(uint64(dscptr.ProviderAvailID), 32)
+	}
+	if summary {
+		out.Summary = dscptr.Summary()
+	}
+	return json.Marshal(out)
+}
+
+/*
+direction returns "out" for a Segmentation Descriptor start, "in" for an end,
+and "" when cue is neither.
+
+	A cue without a start or end, like a legacy Splice Insert
+	with only an Avail Descriptor, falls back to the OutOfNetworkIndicator
+	of a Splice Insert.
+*/
+func (cue *Cue) direction() string {
+	dir := ""
+	cue.EachSegmentation(func(dscptr *Descriptor) {
+		if dir != "" || dscptr.SegmentationEventCancelIndicator {
+			return
+		}
+		if _, ok := segPairs[dscptr.SegmentationTypeID]; ok {
+			dir = "out"
+		}
+		for _, end := range segPairs {
+			if dscptr.SegmentationTypeID == end {
+				dir = "in"
+			}
+		}
+	})
+	if dir != "" {
+		return dir
+	}
+	if cue.Command != nil && cue.Command.CommandType == 0x5 && !cue.Command.SpliceEventCancelIndicator {
+		if cue.Command.OutOfNetworkIndicator {
+			return "out"
+		}
+		return "in"
+	}
+	return ""
+}
+
+/*
+IsOut returns true when cue starts a break.
+
+	A Segmentation Descriptor start type is out, an end type is in,
+	without either a Splice Insert is out when OutOfNetworkIndicator is set.
+	Cancelled events are never out.
+*/
+func (cue *Cue) IsOut() bool {
+	return cue.direction() == "out"
+}
+
+/*
+Attributes returns key values describing cue for annotating a trace span.
+
+	scte35.command.type       command type in hex, like 0x5
+	scte35.command.name       command name
+	scte35.event_id           SpliceEventID or the first SegmentationEventID, in hex
+	scte35.pts                splice time plus PtsAdjustment in seconds, when there is one
+	scte35.segmentation.type  segmentation type ids in hex, comma separated
+	scte35.direction          out or in
+	scte35.crc_valid          true or false
+
+	Keys without a value for cue are left out.
+*/
+func (cue *Cue) Attributes() map[string]string {
+	attrs := map[string]string{}
+	if cmd := cue.Command; cmd != nil {
+		attrs["scte35.command.type"] = fmt.Sprintf("%#x", cmd.CommandType)
+		attrs["scte35.command.name"] = cmd.Name
+		if cmd.CommandType == 0x5 {
+			attrs["scte35.event_id"] = hexID(uint64(cmd.SpliceEventID), 32)
+		}
+		if cmd.TimeSpecifiedFlag && !cue.IsImmediate() {
+			attrs["scte35.pts"] = strconv.FormatFloat(mk90k(uint64(cue.adjustedTicks())), 'f', 6, 64)
+		}
+	}
+	var types []string
+	cue.EachSegmentation(func(dscptr *Descriptor) {
+		types = append(types, fmt.Sprintf("%#x", dscptr.SegmentationTypeID))
+		if _, ok := attrs["scte35.event_id"]; !ok && dscptr.SegmentationEventID != "" {
+			attrs["scte35.event_id"] = hexID(uint64(hexValue(dscptr.SegmentationEventID)), 32)
+		}
+	})
+	if len(types) > 0 {
+		attrs["scte35.segmentation.type"] = strings.Join(types, ",")
+	}
+	if dir := cue.direction(); dir != "" {
+		attrs["scte35.direction"] = dir
+	}
+	if cue.InfoSection != nil {
+		level, _ := chkCrc32(cue)
+		attrs["scte35.crc_valid"] = strconv.FormatBool(level == Pass)
+	}
+	return attrs
+}
+
+/*
+String returns a one line summary of cue for logs, like
+
+	SpliceInsert OUT evt=1207959695 pts=21514.559088 dur=60.293566 autoReturn
+	TimeSignal pts=10.0 seg="Provider Placement Opportunity Start" upid=0xc
+
+	Each Segmentation Descriptor adds its type name and UPID type.
+*/
+func (cue *Cue) String() string {
+	cmd := cue.Command
+	if cmd == nil {
+		return "Cue without a command"
+	}
+	b := make([]byte, 0, 80)
+	for i := 0; i < len(cmd.Name); i++ {
+		if cmd.Name[i] != ' ' {
+			b = append(b, cmd.Name[i])
+		}
+	}
+	if cmd.Name == "" {
+		b = strconv.AppendUint(append(b, "Unknown type=0x"...), uint64(cmd.CommandType), 16)
+	}
+	switch cmd.CommandType {
+	case 0x4:
+		b = strconv.AppendInt(append(b, " events="...), int64(len(cmd.SpliceEvents)), 10)
+	case 0x5:
+		if cmd.SpliceEventCancelIndicator {
+			b = append(b, " CANCEL"...)
+		} else if cmd.OutOfNetworkIndicator {
+			b = append(b, " OUT"...)
+		} else {
+			b = append(b, " IN"...)
+		}
+		b = strconv.AppendUint(append(b, " evt="...), uint64(cmd.SpliceEventID), 10)
+		if cmd.SpliceEventCancelIndicator {
+			break
+		}
+		if cmd.SpliceImmediateFlag {
+			b = append(b, " immediate"...)
+		} else if cmd.TimeSpecifiedFlag {
+			b = appendSecs(append(b, " pts="...), cmd.PTS)
+		}
+		if cmd.DurationFlag {
+			b = appendSecs(append(b, " dur="...), cmd.BreakDuration)
+			if cmd.BreakAutoReturn {
+				b = append(b, " autoReturn"...)
+			}
+		}
+	case 0x6:
+		if cmd.TimeSpecifiedFlag {
+			b = appendSecs(append(b, " pts="...), cmd.PTS)
+		}
+	}
+	for i := range cue.Descriptors {
+		dscptr := &cue.Descriptors[i]
+		if dscptr.Tag != 0x2 {
+			continue
+		}
+		if name, ok := table22[dscptr.SegmentationTypeID]; ok {
+			b = strconv.AppendQuote(append(b, " seg="...), name)
+		} else {
+			b = strconv.AppendUint(append(b, " seg=0x"...), uint64(dscptr.SegmentationTypeID), 16)
+		}
+		if dscptr.SegmentationUpidType != 0 {
+			b = strconv.AppendUint(append(b, " upid=0x"...), uint64(dscptr.SegmentationUpidType), 16)
+		}
+	}
+	return string(b)
+}
+
+// appendSecs appends secs to b in the shortest form, with at least one decimal place.
+func appendSecs(b []byte, secs float64) []byte {
+	start := len(b)
+	b = strconv.AppendFloat(b, secs, 'f', -1, 64)
+	for _, c := range b[start:] {
+		if c == '.' {
+			return b
+		}
+	}
+	return append(b, ".0"...)
+}
+
+/*
+UnmarshalJSON takes Identifier as the 4 char form, like "CUEI",
+a hex string, or a number.
+*/
+func (dscptr *Descriptor) UnmarshalJSON(data []byte) error {
+	type descriptor Descriptor // descriptor has no UnmarshalJSON method
+	in := struct {
+		*descriptor
+		Identifier json.RawMessage
+	}{descriptor: (*descriptor)(dscptr)}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	dscptr.Identifier = 0
+	if len(in.Identifier) == 0 || string(in.Identifier) == "null" {
+		return nil
+	}
+	var id uint32
+	if err := json.Unmarshal(in.Identifier, &id); err == nil {
+		dscptr.Identifier = id
+		return nil
+	}
+	var str string
+	if err := json.Unmarshal(in.Identifier, &str); err != nil {
+		return fmt.Errorf("identifier %s is not a string or a number", in.Identifier)
+	}
+	if len(str) == 4 {
+		dscptr.Identifier = binary.BigEndian.Uint32([]byte(str))
+		return nil
+	}
+	if len(str) == 10 && strings.HasPrefix(str, "0x") {
+		if id, err := strconv.ParseUint(str[2:], 16, 32); err == nil {
+			dscptr.Identifier = uint32(id)
+			return nil
+		}
+	}
+	return fmt.Errorf("identifier %q is not 4 chars or a 32 bit hex id", str)
+}
+
+// identifierString is identifier as 4 chars, or hexID when a byte is not printable.
+func identifierString(identifier uint32) string {
+	bites := make([]byte, 4)
+	binary.BigEndian.PutUint32(bites, identifier)
+	for _, b := range bites {
+		if b < 0x20 || b > 0x7e {
+			return hexID(uint64(identifier), 32)
+		}
+	}
+	return string(bites)
+}
@@ -0,0 +1,132 @@
+package cuei_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestHexIDs(t *testing.T) {
+	insert := cuei.NewCue()
+	insert.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	insert.Command.UniqueProgramID = 0x2a
+	seg := segmentation(0x34)
+	seg.SegmentationEventID = "0x10"
+	schedule := withCommand(&cuei.Command{CommandType: 0x4, SpliceEvents: []cuei.SpliceEvent{
+		{SpliceEventID: 0x162a, ProgramSpliceFlag: true, UniqueProgramID: 0xBEEF},
+	}})
+	for _, c := range []struct {
+		cue  *cuei.Cue
+		want []string
+	}{
+		{insert, []string{`"SpliceEventID":1207959695`, `"SpliceEventIDHex":"0x4800008f"`,
+			`"UniqueProgramID":42`, `"UniqueProgramIDHex":"0x002a"`, `"ProviderAvailIDHex":"0x00000135"`}},
+		{withDescriptors(seg), []string{`"SegmentationEventID":"0x00000010"`}},
+		{schedule, []string{`"SpliceEventIDHex":"0x0000162a"`, `"UniqueProgramIDHex":"0xbeef"`}},
+	} {
+		js, err := json.Marshal(c.cue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range c.want {
+			if !bytes.Contains(js, []byte(want)) {
+				t.Errorf("%s\ndoes not have %s", js, want)
+			}
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	insert := cuei.NewCue()
+	insert.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	end := withDescriptors(segmentation(0x35))
+	end.Command.PTS = 10.0
+	end.InfoSection.PtsAdjustment = 2.5
+	end.Encode()
+	bad := cuei.NewCue()
+	bites := withDescriptors(segmentation(0x35)).Encode()
+	bites[len(bites)-1] ^= 0xff
+	bad.Decode(bites)
+	cases := []struct {
+		cue  *cuei.Cue
+		want map[string]string
+	}{
+		{insert, map[string]string{
+			"scte35.command.type": "0x5",
+			"scte35.command.name": "Splice Insert",
+			"scte35.event_id":     "0x4800008f",
+			"scte35.pts":          "21514.559088",
+			"scte35.direction":    "out",
+			"scte35.crc_valid":    "true",
+		}},
+		{end, map[string]string{
+			"scte35.command.type":      "0x6",
+			"scte35.command.name":      "Time Signal",
+			"scte35.event_id":          "0x4800008f",
+			"scte35.pts":               "12.500000",
+			"scte35.segmentation.type": "0x35",
+			"scte35.direction":         "in",
+			"scte35.crc_valid":         "true",
+		}},
+	}
+	for _, c := range cases {
+		got := c.cue.Attributes()
+		if fmt.Sprint(got) != fmt.Sprint(c.want) {
+			t.Errorf("attributes are %v, want %v", got, c.want)
+		}
+	}
+	if got := bad.Attributes()["scte35.crc_valid"]; got != "false" {
+		t.Errorf("bad crc32 is valid %v", got)
+	}
+}
+
+func TestJsonWith(t *testing.T) {
+	cue := withDescriptors(segmentation(0x34))
+	cue.Command.PTS = 10
+	cue = roundTrip(t, cue)
+	plain, err := json.Marshal(cue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(plain), "Summary") || strings.Contains(cue.JsonWith(cuei.JSONOptions{}), "Summary") {
+		t.Errorf("Summary is in the default JSON")
+	}
+	js := cue.JsonWith(cuei.JSONOptions{Summaries: true})
+	if n := strings.Count(js, `"Summary"`); n != 2 {
+		t.Errorf("%v Summaries, want one for the Command and one for the Descriptor", n)
+	}
+	var back cuei.Cue
+	if err := json.Unmarshal([]byte(js), &back); err != nil || back.Descriptors[0].SegmentationTypeID != 0x34 {
+		t.Errorf("JsonWith does not load back, %v", err)
+	}
+}
+
+func TestIdentifierJSON(t *testing.T) {
+	for id, want := range map[uint32]string{0x43554549: `"CUEI"`, 0x01020304: `"0x01020304"`} {
+		js, err := json.Marshal(cuei.Descriptor{Tag: 0xf0, Identifier: id})
+		if err != nil || !strings.Contains(string(js), `"Identifier":`+want) {
+			t.Errorf("identifier %#x marshals as %s, %v", id, js, err)
+		}
+		var back cuei.Descriptor
+		if err := json.Unmarshal(js, &back); err != nil || back.Identifier != id {
+			t.Errorf("identifier %s unmarshals as %#x, %v", want, back.Identifier, err)
+		}
+	}
+	var dscptr cuei.Descriptor
+	if err := json.Unmarshal([]byte(`{"Tag": 2, "Identifier": 1129661769}`), &dscptr); err != nil || dscptr.Identifier != 0x43554549 || dscptr.Tag != 2 {
+		t.Errorf("numeric identifier unmarshals as %+v, %v", dscptr, err)
+	}
+	if err := json.Unmarshal([]byte(`{"Identifier": "CUE"}`), &dscptr); err == nil {
+		t.Error("3 char identifier did not fail")
+	}
+	cue := cuei.NewCue()
+	cue.Decode(legacyAvail)
+	back := cuei.Json2Cue(cue.JsonWith(cuei.JSONOptions{}))
+	if got, want := back.Encode2B64(), cue.Encode2B64(); got != want {
+		t.Errorf("JSON round trip encodes %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,107 @@
+package cuei
+
+import (
+	"fmt"
+)
+
+/*
+NewSegmentation returns a program Segmentation Descriptor
+for use as a template.
+
+	EventIDComplianceIndicator is set, as the spec recommends.
+	An EIDR upid is encoded as its 12 bytes,
+	a upid of more than 255 bytes is left out.
+
+	The With methods return a copy,
+	so the template can be reused for many Cues.
+
+	tmpl := cuei.NewSegmentation(0x34, 0x0f, "https://example.com/ad")
+	cue.AddDescriptor(tmpl.WithEventID(7).WithDuration(30.0))
+*/
+func NewSegmentation(typeID uint8, upidType uint8, upid string) Descriptor {
+	dscptr := Descriptor{
+		Tag:                        0x2,
+		Identifier:                 cueIdentifier,
+		Name:                       "Segmentation Descriptor",
+		SegmentationEventID:        "0x0",
+		EventIDComplianceIndicator: true,
+		ProgramSegmentationFlag:    true,
+		DeliveryNotRestrictedFlag:  true,
+		SegmentationTypeID:         typeID,
+		SegmentationMessage:        table22[typeID],
+		SegmentationUpidType:       upidType,
+	}
+	if len(upid) > 0 {
+		u, err := eidrUpid(upid)
+		if upidType != 0x0a || err != nil {
+			u = &Upid{Name: uriUpids[upidType], UpidType: upidType, Value: upid}
+		}
+		// SegmentationUpidLength is the length of the encoded upid
+		dscptr.setUpid(u, nil)
+	}
+	return dscptr
+}
+
+// WithEventID returns a copy of dscptr with SegmentationEventID set to id.
+func (dscptr Descriptor) WithEventID(id uint32) Descriptor {
+	d := dscptr.copy()
+	d.SegmentationEventID = fmt.Sprintf("%#x", id)
+	return d
+}
+
+// WithDuration returns a copy of dscptr with SegmentationDuration set to secs.
+func (dscptr Descriptor) WithDuration(secs float64) Descriptor {
+	d := dscptr.copy()
+	d.SegmentationDurationFlag = true
+	d.SegmentationDuration = secs
+	return d
+}
+
+// copy returns a copy of dscptr that shares no slices, pointers or Upid with it.
+func (dscptr Descriptor) copy() Descriptor {
+	dscptr.AudioComponents = append([]AudioComponent(nil), dscptr.AudioComponents...)
+	dscptr.SegComponents = append([]SegComponent(nil), dscptr.SegComponents...)
+	dscptr.RawBytes = append([]byte(nil), dscptr.RawBytes...)
+	dscptr.Trailing = append([]byte(nil), dscptr.Trailing...)
+	dscptr.UpidBytes = append([]byte(nil), dscptr.UpidBytes...)
+	if dscptr.RegionalBlackout != nil {
+		blackout := *dscptr.RegionalBlackout
+		dscptr.RegionalBlackout = &blackout
+	}
+	if dscptr.SegmentationUpid != nil {
+		upid := *dscptr.SegmentationUpid
+		upid.Upids = append([]Upid(nil), upid.Upids...)
+		dscptr.SegmentationUpid = &upid
+	}
+	return dscptr
+}
+
+// newCue returns an encoded Cue with a default InfoSection and cmd.
+func newCue(cmd *Command) *Cue {
+	cue := NewCue()
+	cue.InfoSection = &InfoSection{}
+	cue.InfoSection.defaults()
+	cue.Command = cmd
+	cue.Encode()
+	return cue
+}
+
+// NewSpliceCancel returns a Splice Insert that cancels the splice event eventID.
+func NewSpliceCancel(eventID uint32) *Cue {
+	return newCue(&Command{
+		Name:                       "Splice Insert",
+		CommandType:                0x5,
+		SpliceEventID:              eventID,
+		SpliceEventCancelIndicator: true,
+	})
+}
+
+// NewTimeSignalAt returns a Time Signal at ptsSecs with no descriptors.
+func NewTimeSignalAt(ptsSecs float64) *Cue {
+	return newCue(&Command{
+		Name:              "Time Signal",
+		CommandType:       0x6,
+		TimeSpecifiedFlag: true,
+		PTS:               ptsSecs,
+	})
+}
@@ -0,0 +1,64 @@
+package cuei_test
+
+import (
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestEventIDComplianceIndicator(t *testing.T) {
+	tmpl := cuei.NewSegmentation(0x30, 0x0, "")
+	if !tmpl.EventIDComplianceIndicator {
+		t.Error("NewSegmentation EventIDComplianceIndicator is false")
+	}
+	unset := tmpl.WithEventID(2)
+	unset.EventIDComplianceIndicator = false
+	cue := roundTrip(t, withDescriptors(tmpl.WithEventID(1), unset))
+	if !cue.Descriptors[0].EventIDComplianceIndicator || cue.Descriptors[1].EventIDComplianceIndicator {
+		t.Errorf("EventIDComplianceIndicator decoded as %v, %v",
+			cue.Descriptors[0].EventIDComplianceIndicator, cue.Descriptors[1].EventIDComplianceIndicator)
+	}
+}
+
+func TestTemplateCopies(t *testing.T) {
+	tmpl := cuei.NewSegmentation(0x34, 0x0f, "https://example.com/ad")
+	first := tmpl.WithEventID(7).WithDuration(30.0)
+	first.SegmentationUpid.Value = "https://example.com/other"
+	if tmpl.SegmentationEventID != "0x0" || tmpl.SegmentationDurationFlag || tmpl.SegmentationUpid.Value != "https://example.com/ad" {
+		t.Errorf("the template changed to %+v", tmpl)
+	}
+	cue := roundTrip(t, withDescriptors(tmpl.WithEventID(7).WithDuration(30.0)))
+	got := cue.Descriptors[0]
+	if got.SegmentationEventID != "0x7" || got.SegmentationDuration != 30.0 || got.SegmentationUpid == nil || got.SegmentationUpid.Value != "https://example.com/ad" {
+		t.Errorf("templated descriptor decoded as %+v", got)
+	}
+}
+
+func TestTemplateCues(t *testing.T) {
+	cancel := roundTrip(t, cuei.NewSpliceCancel(9))
+	if cmd := cancel.Command; cmd.CommandType != 0x5 || cmd.SpliceEventID != 9 || !cmd.SpliceEventCancelIndicator {
+		t.Errorf("splice cancel decoded as %+v", cmd)
+	}
+	signal := roundTrip(t, cuei.NewTimeSignalAt(1.5))
+	if cmd := signal.Command; cmd.CommandType != 0x6 || !cmd.TimeSpecifiedFlag || cmd.PTS != 1.5 {
+		t.Errorf("time signal decoded as %+v", cmd)
+	}
+	for _, cue := range []*cuei.Cue{cancel, signal} {
+		if err := cue.Validate(); err != nil {
+			t.Errorf("command type %#x: %v", cue.Command.CommandType, err)
+		}
+	}
+}
+
+func TestNewSegmentationEIDR(t *testing.T) {
+	eidr := "10.5240/7791-8534-2C23-9030-8610-5"
+	tmpl := cuei.NewSegmentation(0x34, 0x0a, eidr)
+	if tmpl.SegmentationUpidLength != 12 {
+		t.Errorf("upid length is %v, want 12", tmpl.SegmentationUpidLength)
+	}
+	cue := roundTrip(t, withDescriptors(tmpl.WithEventID(1)))
+	got := cue.Descriptors[0]
+	if len(cue.Warnings) > 0 || got.SegmentationUpid == nil || got.SegmentationUpid.Value != eidr || got.SegmentationUpidLength != 12 {
+		t.Errorf("EIDR descriptor decoded as %+v, warnings %v", got, cue.Warnings)
+	}
+}
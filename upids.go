@@ -1,7 +1,12 @@
 package cuei
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
 )
 
 var uriUpids = map[uint8]string{
@@ -13,10 +18,7 @@ var uriUpids = map[uint8]string{
 	0x09: "ADI",
 	0x10: "UUID",
 	0x11: "ACR",
-	0x0a: "EIDR",
 	0x0b: "ATSC",
-	0x0c: "MPU",
-	0x0d: "MID",
 	0x0e: "ADS Info",
 	0x0f: "URI",
 }
@@ -40,6 +42,35 @@ type Upid struct {
 	PrivateData      []byte `json:",omitempty"`
 }
 
+/*
+UPID is a Upid from a Segmentation Descriptor,
+with the SegmentationEventID of the descriptor.
+
+	Value is empty for ATSC and MPU Upids.
+*/
+type UPID struct {
+	SegmentationEventID string
+	Type                uint8
+	Name                string
+	Value               string
+}
+
+// flatten appends upid, or the Upids of a MID, to upids.
+func (upid *Upid) flatten(upids []UPID, eventID string, upidType uint8) []UPID {
+	if upidType == 0x0d {
+		for i := range upid.Upids {
+			upids = upid.Upids[i].flatten(upids, eventID, upid.Upids[i].UpidType)
+		}
+		return upids
+	}
+	return append(upids, UPID{
+		SegmentationEventID: eventID,
+		Type:                upidType,
+		Name:                upid.Name,
+		Value:               upid.Value,
+	})
+}
+
 // Decode Upids
 func (upid *Upid) Decode(bd *bitDecoder, upidType uint8, upidlen uint8) {
 
@@ -101,17 +132,28 @@ func (upid *Upid) atsc(bd *bitDecoder, upidlen uint8) {
 	upid.ContentID = bd.asBytes(uint((upidlen - 4) << 3))
 }
 
-// Decode for EIDR Upid
+// Decode for EIDR Upid, as 10.prefix/XXXX-XXXX-XXXX-XXXX-XXXX-C
 func (upid *Upid) eidr(bd *bitDecoder, upidlen uint8) {
-	if upidlen == 12 {
-		head := bd.uInt64(16)
-		tail := bd.asHex(80)
-		upid.Value = fmt.Sprintf("10%v/%v", head, tail)
+	if upidlen != 12 {
+		upid.uri(bd, upidlen)
+		return
+	}
+	prefix := bd.uInt16(16)
+	suffix := strings.ToUpper(hex.EncodeToString(bd.asBytes(80)))
+	var parts []string
+	for i := 0; i < len(suffix); i += 4 {
+		parts = append(parts, suffix[i:i+4])
 	}
+	parts = append(parts, string(eidrCheck(suffix)))
+	upid.Value = fmt.Sprintf("10.%v/%v", prefix, strings.Join(parts, "-"))
 }
 
 // Decode for MPU Upid
 func (upid *Upid) mpu(bd *bitDecoder, upidlen uint8) {
+	if upidlen < 4 {
+		upid.uri(bd, upidlen)
+		return
+	}
 	ulb := uint(upidlen) << 3
 	upid.FormatIdentifier = bd.asHex(32)
 	upid.PrivateData = bd.asBytes(ulb - 32)
@@ -119,27 +161,33 @@ func (upid *Upid) mpu(bd *bitDecoder, upidlen uint8) {
 
 // Decode for MID Upid
 func (upid *Upid) mid(bd *bitDecoder, upidlen uint8) {
-	var i uint8
-	i = 0
-	for i < upidlen {
+	// i is an int so a long last upid can not wrap it around
+	i := 0
+	for i < int(upidlen) {
 		utype := bd.uInt8(8)
 		i++
 		ulen := bd.uInt8(8)
 		i++
-		i += ulen
+		i += int(ulen)
 		var mupid Upid
-		upid.Decode(bd, utype, ulen)
+		mupid.Decode(bd, utype, ulen)
 		upid.Upids = append(upid.Upids, mupid)
 	}
 }
 
-// Encode Upids
+// Encode Upids, upidType is the Descriptor SegmentationUpidType.
 func (upid *Upid) Encode(be *bitEncoder, upidType uint8) {
-	switch upid.UpidType {
+	switch upidType {
 	case 0x05, 0x06:
 		upid.encodeIsan(be)
 	case 0x08:
 		upid.encodeAirId(be)
+	case 0x0a:
+		upid.encodeEidr(be)
+	case 0x0c:
+		upid.encodeMpu(be)
+	case 0x0d:
+		upid.encodeMid(be)
 	default:
 		upid.encodeUri(be)
 	}
@@ -160,9 +208,246 @@ func (upid *Upid) encodeAirId(be *bitEncoder) {
 	}
 }
 
+// encode for MID Upid, each Upid is written with its type and length
+func (upid *Upid) encodeMid(be *bitEncoder) {
+	for _, mupid := range upid.Upids {
+		bf := &bitEncoder{}
+		bf.Add(1, 8) //bumper to keep leading zeros
+		mupid.Encode(bf, mupid.UpidType)
+		be.Add(mupid.UpidType, 8)
+		be.Add(len(bf.Bites.Bytes())-1, 8)
+		mupid.Encode(be, mupid.UpidType)
+	}
+}
+
 // encode for Isan Upid
 func (upid *Upid) encodeIsan(be *bitEncoder) {
 	if len(upid.Value) > 0 {
 		be.AddBytes([]byte(upid.Value), uint(len(upid.Value)<<3))
 	}
 }
+
+// encode for EIDR Upid, a Value that is not an EIDR is written as is.
+func (upid *Upid) encodeEidr(be *bitEncoder) {
+	prefix, suffix, err := parseEIDR(upid.Value)
+	if err != nil {
+		upid.encodeUri(be)
+		return
+	}
+	be.Add(prefix, 16)
+	be.AddBytes(suffix, 80)
+}
+
+// encode for MPU Upid, without a FormatIdentifier the Value is written as is.
+func (upid *Upid) encodeMpu(be *bitEncoder) {
+	if upid.FormatIdentifier == "" {
+		upid.encodeUri(be)
+		return
+	}
+	be.AddHex64(upid.FormatIdentifier, 32)
+	be.AddBytes(upid.PrivateData, uint(len(upid.PrivateData))<<3)
+}
+
+// eidrChars are the ISO 7064 Mod 37,36 characters.
+const eidrChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// eidrCheck returns the ISO 7064 Mod 37,36 check character of the hex digits of an EIDR suffix.
+func eidrCheck(digits string) byte {
+	p := 36
+	for _, c := range digits {
+		s := (p + strings.IndexRune(eidrChars, c)) % 36
+		if s == 0 {
+			s = 36
+		}
+		p = s * 2 % 37
+	}
+	return eidrChars[(37-p)%36]
+}
+
+/*
+parseEIDR splits an EIDR like 10.5240/7791-8534-2C23-9030-8610-5
+into its prefix and the 10 bytes of its suffix.
+
+	The dashes are optional, the check character is required.
+*/
+func parseEIDR(eidr string) (prefix uint16, suffix []byte, err error) {
+	head, tail, ok := strings.Cut(eidr, "/")
+	if !ok || !strings.HasPrefix(head, "10.") {
+		return 0, nil, fmt.Errorf("eidr %q is not 10.prefix/suffix", eidr)
+	}
+	n, err := strconv.ParseUint(head[3:], 10, 16)
+	if err != nil {
+		return 0, nil, fmt.Errorf("eidr %q prefix is not a 16 bit number", eidr)
+	}
+	tail = strings.ToUpper(strings.ReplaceAll(tail, "-", ""))
+	if len(tail) != 21 {
+		return 0, nil, fmt.Errorf("eidr %q suffix is not 20 hex digits and a check character", eidr)
+	}
+	suffix, err = hex.DecodeString(tail[:20])
+	if err != nil {
+		return 0, nil, fmt.Errorf("eidr %q suffix is not hex", eidr)
+	}
+	if check := eidrCheck(tail[:20]); tail[20] != check {
+		return 0, nil, fmt.Errorf("eidr %q check character is %c, want %c", eidr, tail[20], check)
+	}
+	return uint16(n), suffix, nil
+}
+
+// adIDUpid returns an AdID Upid, 12 upper case letters and digits.
+func adIDUpid(adID string) (*Upid, error) {
+	if len(adID) != 12 {
+		return nil, fmt.Errorf("ad-id %q is not 12 characters", adID)
+	}
+	for _, c := range adID {
+		if !strings.ContainsRune(eidrChars, c) {
+			return nil, fmt.Errorf("ad-id %q has %q, only upper case letters and digits are allowed", adID, c)
+		}
+	}
+	return &Upid{Name: "AdID", UpidType: 0x03, Value: adID}, nil
+}
+
+// eidrUpid returns an EIDR Upid in the form eidr decodes.
+func eidrUpid(eidr string) (*Upid, error) {
+	prefix, suffix, err := parseEIDR(eidr)
+	if err != nil {
+		return nil, err
+	}
+	var bd bitDecoder
+	bd.load(append([]byte{byte(prefix >> 8), byte(prefix)}, suffix...))
+	upid := &Upid{Name: "EIDR", UpidType: 0x0a}
+	upid.eidr(&bd, 12)
+	return upid, nil
+}
+
+// uriUpid returns a URI Upid, uri must have a scheme.
+func uriUpid(uri string) (*Upid, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("uri %q has no scheme", uri)
+	}
+	return &Upid{Name: "URI", UpidType: 0x0f, Value: uri}, nil
+}
+
+/*
+midUpid returns a MID Upid holding a Upid for each of upids,
+from its Type and Value.
+
+	AdID, EIDR and URI Values are checked like their setters,
+	other types are written as Value bytes,
+	MPU and MID can not be built from a Value.
+*/
+func midUpid(upids []UPID) (*Upid, error) {
+	mid := &Upid{Name: "MID", UpidType: 0x0d}
+	for i, u := range upids {
+		var upid *Upid
+		var err error
+		switch u.Type {
+		case 0x03:
+			upid, err = adIDUpid(u.Value)
+		case 0x0a:
+			upid, err = eidrUpid(u.Value)
+		case 0x0f:
+			upid, err = uriUpid(u.Value)
+		case 0x0c, 0x0d:
+			err = fmt.Errorf("type %#x can not be built from a Value", u.Type)
+		default:
+			upid = &Upid{Name: u.Name, UpidType: u.Type, Value: u.Value}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("mid upid %v: %v", i, err)
+		}
+		if len(u.Value) > 255 {
+			return nil, fmt.Errorf("mid upid %v is more than 255 bytes", i)
+		}
+		mid.Upids = append(mid.Upids, *upid)
+	}
+	return mid, nil
+}
+
+// setUpid sets the SegmentationUpid of dscptr to upid, with its type and encoded length.
+func (dscptr *Descriptor) setUpid(upid *Upid, err error) error {
+	if err != nil {
+		return err
+	}
+	be := &bitEncoder{}
+	be.Add(1, 8) //bumper to keep leading zeros
+	upid.Encode(be, upid.UpidType)
+	n := len(be.Bites.Bytes()) - 1
+	if n > 255 {
+		return fmt.Errorf("upid is %v bytes, more than 255", n)
+	}
+	dscptr.SegmentationUpidType = upid.UpidType
+	dscptr.SegmentationUpidLength = uint8(n)
+	dscptr.SegmentationUpid = upid
+	dscptr.UpidBytes = nil
+	dscptr.upidErr = nil
+	return nil
+}
+
+/*
+ParseUPID returns the SegmentationUpid of dscptr, decoding UpidBytes
+and caching the result in SegmentationUpid the first time
+when dscptr was decoded by a Decoder with LazyUPIDs.
+
+	It returns nil and no error when there is no upid.
+	The upid is decoded from UpidBytes alone, so a MID with a upid
+	past the end of its bytes is an error here, even though a Decoder
+	without LazyUPIDs reads on into the descriptor without a warning.
+	The error is cached too.
+*/
+func (dscptr *Descriptor) ParseUPID() (*Upid, error) {
+	if dscptr.Tag != 0x2 {
+		return nil, errors.New("not a segmentation descriptor")
+	}
+	if dscptr.SegmentationUpid != nil || dscptr.upidErr != nil || len(dscptr.UpidBytes) == 0 {
+		return dscptr.SegmentationUpid, dscptr.upidErr
+	}
+	var bd bitDecoder
+	bd.load(dscptr.UpidBytes)
+	upid := &Upid{}
+	upid.Decode(&bd, dscptr.SegmentationUpidType, uint8(len(dscptr.UpidBytes)))
+	if bd.overrun || bd.idx != uint(len(dscptr.UpidBytes))<<3 {
+		dscptr.upidErr = fmt.Errorf("upid type %#x does not fit its %v bytes", dscptr.SegmentationUpidType, len(dscptr.UpidBytes))
+		return nil, dscptr.upidErr
+	}
+	dscptr.SegmentationUpid = upid
+	dscptr.UpidBytes = nil
+	return upid, nil
+}
+
+// SetUPIDAdID sets an AdID Upid, adID must be 12 upper case letters and digits.
+func (dscptr *Descriptor) SetUPIDAdID(adID string) error {
+	return dscptr.setUpid(adIDUpid(adID))
+}
+
+// SetUPIDEIDR sets an EIDR Upid, eidr is like 10.5240/7791-8534-2C23-9030-8610-5 with a valid check character.
+func (dscptr *Descriptor) SetUPIDEIDR(eidr string) error {
+	return dscptr.setUpid(eidrUpid(eidr))
+}
+
+// SetUPIDMPU sets an MPU Upid, the 4 byte formatID is followed by at most 251 bytes of data.
+func (dscptr *Descriptor) SetUPIDMPU(formatID uint32, data []byte) error {
+	if len(data) > 251 {
+		return errors.New("mpu data is more than 251 bytes")
+	}
+	upid := &Upid{
+		Name:             "MPU",
+		UpidType:         0x0c,
+		FormatIdentifier: fmt.Sprintf("%#x", formatID),
+		PrivateData:      append([]byte{}, data...),
+	}
+	return dscptr.setUpid(upid, nil)
+}
+
+// SetUPIDURI sets a URI Upid, uri must parse and have a scheme.
+func (dscptr *Descriptor) SetUPIDURI(uri string) error {
+	return dscptr.setUpid(uriUpid(uri))
+}
+
+// SetUPIDMID sets a MID Upid of upids, see midUpid for how each is built.
+func (dscptr *Descriptor) SetUPIDMID(upids []UPID) error {
+	return dscptr.setUpid(midUpid(upids))
+}
@@ -0,0 +1,119 @@
+package cuei_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestUPIDs(t *testing.T) {
+	mid := segmentation(0x34)
+	mid.SegmentationUpidType = 0x0d
+	mid.SegmentationUpidLength = 25 // 2+12 AdID, 2+9 URI
+	mid.SegmentationUpid = &cuei.Upid{Upids: []cuei.Upid{
+		{UpidType: 0x03, Value: "ABCD0123456H"},
+		{UpidType: 0x0f, Value: "urn:a:b:c"},
+	}}
+	adi := segmentation(0x10)
+	adi.SegmentationEventID = "0x4800008e"
+	adi.SegmentationUpidType = 0x09
+	adi.SegmentationUpidLength = 8
+	adi.SegmentationUpid = &cuei.Upid{Value: "PREFIX:1"}
+
+	cue := roundTrip(t, withDescriptors(mid, adi))
+	want := []cuei.UPID{
+		{SegmentationEventID: "0x4800008f", Type: 0x03, Name: "AdID", Value: "ABCD0123456H"},
+		{SegmentationEventID: "0x4800008f", Type: 0x0f, Name: "URI", Value: "urn:a:b:c"},
+		{SegmentationEventID: "0x4800008e", Type: 0x09, Name: "ADI", Value: "PREFIX:1"},
+	}
+	got := cue.UPIDs()
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("UPIDs are %v, want %v", got, want)
+	}
+}
+
+func TestUPIDSetters(t *testing.T) {
+	eidr := "10.5240/7791-8534-2C23-9030-8610-5"
+	setters := []struct {
+		set   func(*cuei.Descriptor) error
+		typ   uint8
+		len   uint8
+		value string
+	}{
+		{func(d *cuei.Descriptor) error { return d.SetUPIDAdID("ABCD0001000H") }, 0x03, 12, "ABCD0001000H"},
+		{func(d *cuei.Descriptor) error { return d.SetUPIDEIDR("10.5240/779185342C2390308610-5") }, 0x0a, 12, eidr},
+		{func(d *cuei.Descriptor) error { return d.SetUPIDMPU(0x41424344, []byte{1, 2, 3}) }, 0x0c, 7, ""},
+		{func(d *cuei.Descriptor) error { return d.SetUPIDURI("urn:uuid:f81d4fae") }, 0x0f, 17, "urn:uuid:f81d4fae"},
+	}
+	for _, s := range setters {
+		dscptr := segmentation(0x34)
+		if err := s.set(&dscptr); err != nil {
+			t.Fatal(err)
+		}
+		got := roundTrip(t, withDescriptors(dscptr)).Descriptors[0]
+		if got.SegmentationUpidType != s.typ || got.SegmentationUpidLength != s.len || got.SegmentationUpid.Value != s.value {
+			t.Errorf("upid type %#x length %v value %q, want %#x %v %q", got.SegmentationUpidType,
+				got.SegmentationUpidLength, got.SegmentationUpid.Value, s.typ, s.len, s.value)
+		}
+		if s.typ == 0x0c && (got.SegmentationUpid.FormatIdentifier != "0x41424344" || !bytes.Equal(got.SegmentationUpid.PrivateData, []byte{1, 2, 3})) {
+			t.Errorf("mpu upid is %+v", got.SegmentationUpid)
+		}
+	}
+
+	dscptr := segmentation(0x34)
+	err := dscptr.SetUPIDMID([]cuei.UPID{{Type: 0x03, Value: "ABCD0001000H"}, {Type: 0x0a, Value: eidr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	upids := roundTrip(t, withDescriptors(dscptr)).UPIDs()
+	if len(upids) != 2 || upids[0].Value != "ABCD0001000H" || upids[1].Value != eidr || dscptr.SegmentationUpidLength != 28 {
+		t.Errorf("mid length %v upids %+v", dscptr.SegmentationUpidLength, upids)
+	}
+
+	bad := []func(*cuei.Descriptor) error{
+		func(d *cuei.Descriptor) error { return d.SetUPIDAdID("abcd0001000h") },
+		func(d *cuei.Descriptor) error { return d.SetUPIDAdID("ABCD") },
+		func(d *cuei.Descriptor) error { return d.SetUPIDEIDR("10.5240/7791-8534-2C23-9030-8610-6") },
+		func(d *cuei.Descriptor) error { return d.SetUPIDEIDR("10.5240/7791-8534") },
+		func(d *cuei.Descriptor) error { return d.SetUPIDMPU(1, make([]byte, 252)) },
+		func(d *cuei.Descriptor) error { return d.SetUPIDURI("no scheme") },
+		func(d *cuei.Descriptor) error { return d.SetUPIDMID([]cuei.UPID{{Type: 0x0d}}) },
+	}
+	for i, set := range bad {
+		dscptr := segmentation(0x34)
+		if err := set(&dscptr); err == nil || dscptr.SegmentationUpid != nil {
+			t.Errorf("bad setter %v did not fail", i)
+		}
+	}
+}
+
+func TestLazyUPIDViews(t *testing.T) {
+	bites := withDescriptors(midSegmentation(2, 20)).Encode()
+	eager, _ := cuei.NewDecoder().Decode(bites)
+	lazy := cuei.NewDecoder()
+	lazy.LazyUPIDs = true
+	cue, err := lazy.Decode(bites)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, want := cue.Value().Descriptors[0].SegmentationUpid, eager.Value().Descriptors[0].SegmentationUpid
+	if len(want) == 0 || !bytes.Equal(got, want) {
+		t.Errorf("lazy Value upid is %x, want %x", got, want)
+	}
+	if cue.Descriptors[0].SegmentationUpid != nil {
+		t.Error("Value parsed the upid")
+	}
+	if got, want := cue.String(), eager.String(); got != want {
+		t.Errorf("lazy String is %v, want %v", got, want)
+	}
+	if got, want := cue.Descriptors[0].Summary(), eager.Descriptors[0].Summary(); got != want || !strings.Contains(got, "UPID(") {
+		t.Errorf("lazy Summary is %v, want %v", got, want)
+	}
+	cue, _ = lazy.Decode(bites)
+	if got, want := fmt.Sprint(cue.UPIDs()), fmt.Sprint(eager.UPIDs()); len(eager.UPIDs()) != 2 || got != want {
+		t.Errorf("lazy UPIDs are %v, want %v", got, want)
+	}
+}
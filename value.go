@@ -0,0 +1,287 @@
+package cuei
+
+/*
+CueValue is a flat copy of a Cue with no pointers,
+for mapping to protobuf messages.
+
+	Times are 90k ticks, hex strings are numbers,
+	and each Upid is the bytes Encode writes for it.
+	Names, Warnings, PacketData and the bytes the Cue was decoded from
+	are left out, but not the encrypted bytes of a Ciphertext Cue.
+*/
+type CueValue struct {
+	TableID                uint32
+	SectionSyntaxIndicator bool
+	Private                bool
+	Reserved               uint32
+	SectionLength          uint32
+	ProtocolVersion        uint32
+	EncryptedPacket        bool
+	EncryptionAlgorithm    uint32
+	PtsAdjustment          uint64
+	CwIndex                uint32
+	Tier                   uint32
+	CommandLength          uint32
+	CommandType            uint32
+	Command                CommandValue
+	DescriptorLoopLength   uint32
+	Descriptors            []DescriptorValue
+	Stuffing               uint32 // alignment_stuffing bytes before the crc32
+	Crc32                  uint32
+	Ciphertext             bool // Command.RawBytes are the encrypted bytes
+}
+
+// CommandValue is a flat copy of a Command.
+type CommandValue struct {
+	CommandType                uint32
+	Identifier                 uint32
+	PrivateBytes               []byte
+	SpliceEventID              uint32
+	SpliceEventCancelIndicator bool
+	OutOfNetworkIndicator      bool
+	ProgramSpliceFlag          bool
+	DurationFlag               bool
+	BreakAutoReturn            bool
+	BreakDuration              uint64
+	SpliceImmediateFlag        bool
+	UniqueProgramID            uint32
+	AvailNum                   uint32
+	AvailExpected              uint32
+	TimeSpecifiedFlag          bool
+	PTS                        uint64
+	Components                 []ComponentValue
+	SpliceEvents               []SpliceEventValue
+	RawBytes                   []byte // the encrypted bytes of a Ciphertext Cue, or nil
+}
+
+/*
+ComponentValue is a flat copy of a SpliceComponent,
+ScheduleComponent or SegComponent.
+
+	PTS is the PTS of a SpliceComponent or the PtsOffset of a SegComponent.
+*/
+type ComponentValue struct {
+	ComponentTag      uint32
+	TimeSpecifiedFlag bool
+	PTS               uint64
+	UTCSpliceTime     uint32
+	TimeUnspecified   bool
+}
+
+// SpliceEventValue is a flat copy of a SpliceEvent.
+type SpliceEventValue struct {
+	SpliceEventID              uint32
+	SpliceEventCancelIndicator bool
+	OutOfNetworkIndicator      bool
+	ProgramSpliceFlag          bool
+	DurationFlag               bool
+	UTCSpliceTime              uint32
+	TimeUnspecified            bool
+	Components                 []ComponentValue
+	BreakAutoReturn            bool
+	BreakDuration              uint64
+	UniqueProgramID            uint32
+	AvailNum                   uint32
+	AvailExpected              uint32
+}
+
+// AudioComponentValue is a flat copy of an Audio Descriptor component.
+type AudioComponentValue struct {
+	ComponentTag  uint32
+	ISOCode       uint32
+	BitstreamMode uint32
+	NumChannels   uint32
+	FullSrvcAudio bool
+}
+
+// DescriptorValue is a flat copy of a Descriptor.
+type DescriptorValue struct {
+	Tag                              uint32
+	Length                           uint32
+	Identifier                       uint32
+	AudioComponents                  []AudioComponentValue
+	ProviderAvailID                  uint32
+	PreRoll                          uint32
+	DTMFCount                        uint32
+	DTMFChars                        uint64
+	TAISeconds                       uint64
+	TAINano                          uint32
+	UTCOffset                        uint32
+	SegmentationEventID              uint32
+	SegmentationEventCancelIndicator bool
+	EventIDComplianceIndicator       bool
+	ProgramSegmentationFlag          bool
+	SegmentationDurationFlag         bool
+	DeliveryNotRestrictedFlag        bool
+	WebDeliveryAllowedFlag           bool
+	NoRegionalBlackoutFlag           bool
+	ArchiveAllowedFlag               bool
+	DeviceRestrictions               uint32
+	Components                       []ComponentValue
+	SegmentationDuration             uint64
+	SegmentationUpidType             uint32
+	SegmentationUpidLength           uint32
+	SegmentationUpid                 []byte
+	SegmentationTypeID               uint32
+	SegmentNum                       uint32
+	SegmentsExpected                 uint32
+	SubSegmentNum                    uint32
+	SubSegmentsExpected              uint32
+	SubSegmentsOmitted               bool
+	RawBytes                         []byte
+	Trailing                         []byte
+}
+
+// Value returns cue as a CueValue.
+func (cue *Cue) Value() CueValue {
+	var val CueValue
+	if infosec := cue.InfoSection; infosec != nil {
+		val.TableID = hexValue(infosec.TableID)
+		val.SectionSyntaxIndicator = infosec.SectionSyntaxIndicator
+		val.Private = infosec.Private
+		val.Reserved = hexValue(infosec.Reserved)
+		val.SectionLength = uint32(infosec.SectionLength)
+		val.ProtocolVersion = uint32(infosec.ProtocolVersion)
+		val.EncryptedPacket = infosec.EncryptedPacket
+		val.EncryptionAlgorithm = uint32(infosec.EncryptionAlgorithm)
+		val.PtsAdjustment = u64(infosec.PtsAdjustment)
+		val.CwIndex = hexValue(infosec.CwIndex)
+		val.Tier = hexValue(infosec.Tier)
+		val.CommandLength = uint32(infosec.CommandLength)
+		val.CommandType = uint32(infosec.CommandType)
+		val.Stuffing = uint32(infosec.Stuffing)
+	}
+	if cue.Command != nil {
+		val.Command = cue.Command.value()
+	}
+	val.DescriptorLoopLength = uint32(cue.Dll)
+	for i := range cue.Descriptors {
+		val.Descriptors = append(val.Descriptors, cue.Descriptors[i].value())
+	}
+	val.Crc32 = cue.Crc32
+	if cue.Ciphertext && cue.Command != nil {
+		val.Ciphertext = true
+		val.Command.RawBytes = append([]byte(nil), cue.Command.RawBytes...)
+	}
+	return val
+}
+
+func (cmd *Command) value() CommandValue {
+	val := CommandValue{
+		CommandType:                uint32(cmd.CommandType),
+		Identifier:                 cmd.Identifier,
+		PrivateBytes:               append([]byte(nil), cmd.PrivateBytes...),
+		SpliceEventID:              cmd.SpliceEventID,
+		SpliceEventCancelIndicator: cmd.SpliceEventCancelIndicator,
+		OutOfNetworkIndicator:      cmd.OutOfNetworkIndicator,
+		ProgramSpliceFlag:          cmd.ProgramSpliceFlag,
+		DurationFlag:               cmd.DurationFlag,
+		BreakAutoReturn:            cmd.BreakAutoReturn,
+		BreakDuration:              u64(cmd.BreakDuration),
+		SpliceImmediateFlag:        cmd.SpliceImmediateFlag,
+		UniqueProgramID:            uint32(cmd.UniqueProgramID),
+		AvailNum:                   uint32(cmd.AvailNum),
+		AvailExpected:              uint32(cmd.AvailExpected),
+		TimeSpecifiedFlag:          cmd.TimeSpecifiedFlag,
+		PTS:                        u64(cmd.PTS),
+	}
+	for _, comp := range cmd.Components {
+		val.Components = append(val.Components, ComponentValue{
+			ComponentTag:      uint32(comp.ComponentTag),
+			TimeSpecifiedFlag: comp.TimeSpecifiedFlag,
+			PTS:               u64(comp.PTS),
+		})
+	}
+	for _, evt := range cmd.SpliceEvents {
+		ev := SpliceEventValue{
+			SpliceEventID:              evt.SpliceEventID,
+			SpliceEventCancelIndicator: evt.SpliceEventCancelIndicator,
+			OutOfNetworkIndicator:      evt.OutOfNetworkIndicator,
+			ProgramSpliceFlag:          evt.ProgramSpliceFlag,
+			DurationFlag:               evt.DurationFlag,
+			UTCSpliceTime:              evt.UTCSpliceTime,
+			TimeUnspecified:            evt.TimeUnspecified,
+			BreakAutoReturn:            evt.BreakAutoReturn,
+			BreakDuration:              u64(evt.BreakDuration),
+			UniqueProgramID:            uint32(evt.UniqueProgramID),
+			AvailNum:                   uint32(evt.AvailNum),
+			AvailExpected:              uint32(evt.AvailExpected),
+		}
+		for _, comp := range evt.Components {
+			ev.Components = append(ev.Components, ComponentValue{
+				ComponentTag:    uint32(comp.ComponentTag),
+				UTCSpliceTime:   comp.UTCSpliceTime,
+				TimeUnspecified: comp.TimeUnspecified,
+			})
+		}
+		val.SpliceEvents = append(val.SpliceEvents, ev)
+	}
+	return val
+}
+
+func (dscptr *Descriptor) value() DescriptorValue {
+	val := DescriptorValue{
+		Tag:                              uint32(dscptr.Tag),
+		Length:                           uint32(dscptr.Length),
+		Identifier:                       dscptr.Identifier,
+		ProviderAvailID:                  dscptr.ProviderAvailID,
+		PreRoll:                          uint32(dscptr.PreRoll),
+		DTMFCount:                        uint32(dscptr.DTMFCount),
+		DTMFChars:                        dscptr.DTMFChars,
+		TAISeconds:                       dscptr.TAISeconds,
+		TAINano:                          dscptr.TAINano,
+		UTCOffset:                        uint32(dscptr.UTCOffset),
+		SegmentationEventID:              hexValue(dscptr.SegmentationEventID),
+		SegmentationEventCancelIndicator: dscptr.SegmentationEventCancelIndicator,
+		EventIDComplianceIndicator:       dscptr.EventIDComplianceIndicator,
+		ProgramSegmentationFlag:          dscptr.ProgramSegmentationFlag,
+		SegmentationDurationFlag:         dscptr.SegmentationDurationFlag,
+		DeliveryNotRestrictedFlag:        dscptr.DeliveryNotRestrictedFlag,
+		WebDeliveryAllowedFlag:           dscptr.WebDeliveryAllowedFlag,
+		NoRegionalBlackoutFlag:           dscptr.NoRegionalBlackoutFlag,
+		ArchiveAllowedFlag:               dscptr.ArchiveAllowedFlag,
+		SegmentationDuration:             u64(dscptr.SegmentationDuration),
+		SegmentationUpidType:             uint32(dscptr.SegmentationUpidType),
+		SegmentationUpidLength:           uint32(dscptr.SegmentationUpidLength),
+		SegmentationTypeID:               uint32(dscptr.SegmentationTypeID),
+		SegmentNum:                       uint32(dscptr.SegmentNum),
+		SegmentsExpected:                 uint32(dscptr.SegmentsExpected),
+		SubSegmentNum:                    uint32(dscptr.SubSegmentNum),
+		SubSegmentsExpected:              uint32(dscptr.SubSegmentsExpected),
+		SubSegmentsOmitted:               dscptr.SubSegmentsOmitted,
+		RawBytes:                         append([]byte(nil), dscptr.RawBytes...),
+		Trailing:                         append([]byte(nil), dscptr.Trailing...),
+	}
+	if dscptr.DeviceRestrictions != "" {
+		val.DeviceRestrictions = uint32(deviceRestrictions(dscptr.DeviceRestrictions))
+	}
+	for _, ac := range dscptr.AudioComponents {
+		val.AudioComponents = append(val.AudioComponents, AudioComponentValue{
+			ComponentTag:  uint32(ac.ComponentTag),
+			ISOCode:       ac.ISOCode,
+			BitstreamMode: uint32(ac.BitstreamMode),
+			NumChannels:   uint32(ac.NumChannels),
+			FullSrvcAudio: ac.FullSrvcAudio,
+		})
+	}
+	for _, comp := range dscptr.SegComponents {
+		val.Components = append(val.Components, ComponentValue{
+			ComponentTag: uint32(comp.ComponentTag),
+			PTS:          u64(comp.PtsOffset),
+		})
+	}
+	if dscptr.Tag == 0x2 {
+		// UpidBytes when not parsed yet, decoded with Decoder.LazyUPIDs
+		val.SegmentationUpid = append([]byte(nil), dscptr.upidBytes()...)
+		val.SegmentationUpidLength = uint32(len(val.SegmentationUpid))
+	}
+	return val
+}
+
+// hexValue converts a hex string like "0xfc" to a number, "" is 0.
+func hexValue(str string) uint32 {
+	if str == "" {
+		return 0
+	}
+	return uint32(hex2Int(str))
+}
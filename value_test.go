@@ -0,0 +1,51 @@
+package cuei_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestValue(t *testing.T) {
+	cue := cuei.NewCue()
+	cue.Decode("/DAvAAAAAAAA///wFAVIAACPf+/+c2nALv4AUsz1AAAAAAAKAAhDVUVJAAABNWLbowo=")
+	val := cue.Value()
+	cmd := val.Command
+	if cmd.PTS != 0x7369c02e || cmd.BreakDuration != 0x52ccf5 || cmd.SpliceEventID != 0x4800008f {
+		t.Errorf("command value is %+v", cmd)
+	}
+	if val.TableID != 0xfc || val.Tier != 0xfff || val.Crc32 != cue.Crc32 || len(val.Descriptors) != 1 {
+		t.Errorf("cue value is %+v", val)
+	}
+	if val.Descriptors[0].ProviderAvailID != 0x135 || val.Descriptors[0].Identifier != 0x43554549 {
+		t.Errorf("descriptor value is %+v", val.Descriptors[0])
+	}
+
+	mpu := segmentation(0x34)
+	mpu.SegmentationUpidType = 0x0c
+	mpu.SegmentationUpidLength = 4
+	mpu.SegmentationUpid = &cuei.Upid{Value: "ABCD"}
+	dval := roundTrip(t, withDescriptors(mpu)).Value().Descriptors[0]
+	if dval.SegmentationEventID != 0x4800008f || string(dval.SegmentationUpid) != "ABCD" || dval.Length != 21 {
+		t.Errorf("segmentation value is %+v", dval)
+	}
+}
+
+func TestValueStuffingCiphertext(t *testing.T) {
+	cue := withDescriptors()
+	if err := cue.PadTo(40); err != nil {
+		t.Fatal(err)
+	}
+	if val := cue.Value(); val.Stuffing != uint32(cue.InfoSection.Stuffing) || val.Stuffing == 0 || val.Ciphertext {
+		t.Errorf("stuffing value is %v, want %v", val.Stuffing, cue.InfoSection.Stuffing)
+	}
+	cue = withDescriptors(segmentation(0x34))
+	cue.InfoSection.EncryptedPacket = true
+	encrypted := cuei.NewCue()
+	encrypted.Decode(cue.Encode())
+	val := encrypted.Value()
+	if !val.Ciphertext || !bytes.Equal(val.Command.RawBytes, encrypted.Command.RawBytes) || len(val.Command.RawBytes) == 0 {
+		t.Errorf("ciphertext value is %v %x", val.Ciphertext, val.Command.RawBytes)
+	}
+}
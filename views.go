@@ -0,0 +1,86 @@
+package cuei
+
+// SpliceInsert holds the Command fields used by a Splice Insert.
+type SpliceInsert struct {
+	SpliceEventID              uint32
+	SpliceEventCancelIndicator bool
+	OutOfNetworkIndicator      bool
+	ProgramSpliceFlag          bool
+	DurationFlag               bool
+	BreakAutoReturn            bool
+	BreakDuration              float64
+	SpliceImmediateFlag        bool
+	TimeSpecifiedFlag          bool
+	PTS                        float64
+	Components                 []SpliceComponent
+	UniqueProgramID            uint16
+	AvailNum                   uint8
+	AvailExpected              uint8
+}
+
+// TimeSignal holds the Command fields used by a Time Signal.
+type TimeSignal struct {
+	TimeSpecifiedFlag bool
+	PTS               float64
+}
+
+// SpliceSchedule holds the Command fields used by a Splice Schedule.
+type SpliceSchedule struct {
+	SpliceEvents []SpliceEvent
+}
+
+/*
+AsSpliceInsert returns a copy of the Splice Insert fields of cmd,
+ok is false if cmd is not a Splice Insert.
+*/
+func (cmd *Command) AsSpliceInsert() (*SpliceInsert, bool) {
+	if cmd.CommandType != 0x5 {
+		return nil, false
+	}
+	return &SpliceInsert{
+		SpliceEventID:              cmd.SpliceEventID,
+		SpliceEventCancelIndicator: cmd.SpliceEventCancelIndicator,
+		OutOfNetworkIndicator:      cmd.OutOfNetworkIndicator,
+		ProgramSpliceFlag:          cmd.ProgramSpliceFlag,
+		DurationFlag:               cmd.DurationFlag,
+		BreakAutoReturn:            cmd.BreakAutoReturn,
+		BreakDuration:              cmd.BreakDuration,
+		SpliceImmediateFlag:        cmd.SpliceImmediateFlag,
+		TimeSpecifiedFlag:          cmd.TimeSpecifiedFlag,
+		PTS:                        cmd.PTS,
+		Components:                 append([]SpliceComponent(nil), cmd.Components...),
+		UniqueProgramID:            cmd.UniqueProgramID,
+		AvailNum:                   cmd.AvailNum,
+		AvailExpected:              cmd.AvailExpected,
+	}, true
+}
+
+/*
+AsTimeSignal returns a copy of the Time Signal fields of cmd,
+ok is false if cmd is not a Time Signal.
+*/
+func (cmd *Command) AsTimeSignal() (*TimeSignal, bool) {
+	if cmd.CommandType != 0x6 {
+		return nil, false
+	}
+	return &TimeSignal{
+		TimeSpecifiedFlag: cmd.TimeSpecifiedFlag,
+		PTS:               cmd.PTS,
+	}, true
+}
+
+/*
+AsSpliceSchedule returns a copy of the Splice Schedule fields of cmd,
+ok is false if cmd is not a Splice Schedule.
+*/
+func (cmd *Command) AsSpliceSchedule() (*SpliceSchedule, bool) {
+	if cmd.CommandType != 0x4 {
+		return nil, false
+	}
+	sched := &SpliceSchedule{}
+	for _, evt := range cmd.SpliceEvents {
+		evt.Components = append([]ScheduleComponent(nil), evt.Components...)
+		sched.SpliceEvents = append(sched.SpliceEvents, evt)
+	}
+	return sched, true
+}
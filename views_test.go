@@ -0,0 +1,37 @@
+package cuei_test
+
+import (
+	"testing"
+
+	"github.com/futzu/cuei"
+)
+
+func TestAsViews(t *testing.T) {
+	insert := cuei.NewCue()
+	insert.Decode(legacyAvail)
+	signal := withDescriptors()
+	sched := withCommand(&cuei.Command{CommandType: 0x4, SpliceEvents: []cuei.SpliceEvent{
+		{SpliceEventID: 1, Components: []cuei.ScheduleComponent{{ComponentTag: 1, UTCSpliceTime: 10}}},
+	}})
+	for _, cue := range []*cuei.Cue{insert, signal, sched} {
+		cmd := cue.Command
+		_, isInsert := cmd.AsSpliceInsert()
+		_, isSignal := cmd.AsTimeSignal()
+		_, isSched := cmd.AsSpliceSchedule()
+		if isInsert != (cmd.CommandType == 0x5) || isSignal != (cmd.CommandType == 0x6) || isSched != (cmd.CommandType == 0x4) {
+			t.Errorf("command type %#x views are insert %v, time signal %v, schedule %v", cmd.CommandType, isInsert, isSignal, isSched)
+		}
+	}
+	si, _ := insert.Command.AsSpliceInsert()
+	if si.SpliceEventID != insert.Command.SpliceEventID || si.PTS != insert.Command.PTS || si.BreakDuration != insert.Command.BreakDuration {
+		t.Errorf("splice insert view is %+v", si)
+	}
+	view, _ := sched.Command.AsSpliceSchedule()
+	view.SpliceEvents[0].Components[0].UTCSpliceTime = 20
+	if got := sched.Command.SpliceEvents[0].Components[0].UTCSpliceTime; got != 10 {
+		t.Errorf("changing the view changed the command to %v", got)
+	}
+	if _, ok := (&cuei.Command{CommandType: 0xff}).AsSpliceInsert(); ok {
+		t.Error("private command is a splice insert")
+	}
+}